@@ -4,18 +4,30 @@
 
 package api
 
-import "time"
+import (
+	"reflect"
+	"time"
+)
 
 type Point Signal
 
+// NewPoint returns a new point without validating it (cf. NewPointBuilder()).
+// Its location is the given *Location, or is captured when location is nil and
+// the location capture is enabled (cf. EnableLocationCapture()).
 func NewPoint(name, source string, t time.Time, actor, trigger, infra, location interface{}, context *SignalContext, payload *SignalPayload) *Point {
-	return (*Point)(newSignal("point", name, source, t, actor, trigger, infra, location, context, payload))
+	b := NewPointBuilder().Name(name).Source(source).Time(t).Actor(actor).Trigger(trigger).Infra(infra).Location(asLocation(location))
+	b.s.SignalContext, b.s.SignalPayload = context, payload
+	return b.build()
 }
 
 type Metric Signal
 
+// NewMetric returns a new metric without validating it (cf.
+// NewMetricBuilder()).
 func NewMetric(name, source string, t time.Time, payload *SignalPayload) *Metric {
-	return (*Metric)(newSignal("metric", name, source, t, nil, nil, nil, nil, nil, payload))
+	b := NewMetricBuilder().Name(name).Source(source).Time(t)
+	b.s.SignalPayload = payload
+	return b.build()
 }
 
 func newSignal(typ, name, source string, t time.Time, actor, trigger, infra, location interface{}, context *SignalContext, payload *SignalPayload) *Signal {
@@ -29,24 +41,43 @@ func newSignal(typ, name, source string, t time.Time, actor, trigger, infra, loc
 		SignalContext: context,
 		Trigger:       trigger,
 		LocationInfra: infra,
-		Location:      location,
+		Location:      asLocation(location),
 	}
 }
 
+// asLocation returns the location given to the constructors, which is nil
+// when it isn't a *Location.
+func asLocation(location interface{}) *Location {
+	loc, _ := location.(*Location)
+	return loc
+}
+
 type (
 	Signal struct {
-		Type          string      `json:"type"`
-		Name          string      `json:"signal_name,omitempty"`
-		Source        string      `json:"source,omitempty"`
-		Time          time.Time   `json:"time,omitempty"`
-		Actor         interface{} `json:"actor,omitempty"`
-		Trigger       interface{} `json:"trigger,omitempty"`
-		LocationInfra interface{} `json:"location_infra,omitempty"`
-		Location      interface{} `json:"location,omitempty"`
+		Type          string       `json:"type"`
+		Name          string       `json:"signal_name,omitempty"`
+		Source        string       `json:"source,omitempty"`
+		Time          time.Time    `json:"time,omitempty"`
+		Actor         interface{}  `json:"actor,omitempty"`
+		Trigger       interface{}  `json:"trigger,omitempty"`
+		LocationInfra interface{}  `json:"location_infra,omitempty"`
+		Location      *Location    `json:"location,omitempty"`
+		Occurrences   *Occurrences `json:"occurrences,omitempty"`
+		// Tags are the signal tags, such as the resource ones (cf.
+		// Resource).
+		Tags map[string]string `json:"tags,omitempty"`
 		*SignalPayload
 		*SignalContext
 	}
 
+	// Occurrences describes the occurrences of a signal collapsing several
+	// identical ones.
+	Occurrences struct {
+		Count     int64     `json:"count"`
+		FirstSeen time.Time `json:"first_seen"`
+		LastSeen  time.Time `json:"last_seen"`
+	}
+
 	SignalPayload struct {
 		Schema  string      `json:"payload_schema,omitempty"`
 		Payload interface{} `json:"payload,omitempty"`
@@ -62,7 +93,13 @@ type (
 // root fields.
 type Trace struct {
 	Signal
-	Data []*Signal `json:"data"`
+	// TraceID, SpanID and ParentID identify the trace in a distributed trace
+	// spanning several services, using W3C Trace Context identifiers. ParentID
+	// is the span ID of the caller, if any.
+	TraceID  string    `json:"trace_id,omitempty"`
+	SpanID   string    `json:"span_id,omitempty"`
+	ParentID string    `json:"parent_id,omitempty"`
+	Data     []*Signal `json:"data"`
 }
 
 func NewTrace(name, source string, t time.Time, actor, trigger, infra, location interface{}, context *SignalContext, payload *SignalPayload, d []*Signal) *Trace {
@@ -101,6 +138,49 @@ func (Signal) isSignal() {}
 func (Point) isSignal()  {}
 func (Metric) isSignal() {}
 
+// base is implemented by pointers to signals, including the types defined
+// from Trace which get it from their embedded Signal.
+type base interface {
+	base() *Signal
+}
+
+func (s *Signal) base() *Signal { return s }
+func (p *Point) base() *Signal  { return (*Signal)(p) }
+func (m *Metric) base() *Signal { return (*Signal)(m) }
+
+// BaseSignal returns the signal fields of s, or nil when s is not a pointer
+// to a signal.
+func BaseSignal(s SignalFace) *Signal {
+	if b, ok := s.(base); ok {
+		return b.base()
+	}
+	return nil
+}
+
+// Copy returns a pointer to a shallow copy of the signal s is or points to,
+// having the same type, or s itself when it is not a signal. It allows to
+// modify the fields of signals owned by the caller, such as in exporters, and
+// to handle value signals like pointer ones.
+func Copy(s SignalFace) SignalFace {
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return s
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return s
+	}
+	cp := reflect.New(v.Type())
+	cp.Elem().Set(v)
+	sig, ok := cp.Interface().(SignalFace)
+	if !ok || BaseSignal(sig) == nil {
+		return s
+	}
+	return sig
+}
+
 // Static assert that SignalFace is correctly implemented.
 var (
 	_ SignalFace = &Trace{}
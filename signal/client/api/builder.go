@@ -0,0 +1,175 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api
+
+import (
+	"errors"
+	"time"
+)
+
+// Signal validation errors returned by the builders.
+var (
+	ErrMissingName          = errors.New("api: missing signal name")
+	ErrMissingPayloadSchema = errors.New("api: missing payload schema")
+	ErrMissingContextSchema = errors.New("api: missing context schema")
+)
+
+// PointBuilder builds a point field by field, such as:
+//
+//	p, err := api.NewPointBuilder().Name("my point").Source("my source").Payload(schema, payload).Build()
+type PointBuilder struct {
+	s Signal
+}
+
+// NewPointBuilder returns a new point builder.
+func NewPointBuilder() *PointBuilder {
+	return &PointBuilder{s: Signal{Type: "point"}}
+}
+
+func (b *PointBuilder) Name(name string) *PointBuilder {
+	b.s.Name = name
+	return b
+}
+
+func (b *PointBuilder) Source(source string) *PointBuilder {
+	b.s.Source = source
+	return b
+}
+
+func (b *PointBuilder) Time(t time.Time) *PointBuilder {
+	b.s.Time = t
+	return b
+}
+
+func (b *PointBuilder) Actor(actor interface{}) *PointBuilder {
+	b.s.Actor = actor
+	return b
+}
+
+func (b *PointBuilder) Trigger(trigger interface{}) *PointBuilder {
+	b.s.Trigger = trigger
+	return b
+}
+
+func (b *PointBuilder) Infra(infra interface{}) *PointBuilder {
+	b.s.LocationInfra = infra
+	return b
+}
+
+func (b *PointBuilder) Location(location *Location) *PointBuilder {
+	b.s.Location = location
+	return b
+}
+
+// Tag adds a tag to the point.
+func (b *PointBuilder) Tag(key, value string) *PointBuilder {
+	if b.s.Tags == nil {
+		b.s.Tags = make(map[string]string)
+	}
+	b.s.Tags[key] = value
+	return b
+}
+
+func (b *PointBuilder) Context(schema string, context interface{}) *PointBuilder {
+	b.s.SignalContext = NewContext(schema, context)
+	return b
+}
+
+func (b *PointBuilder) Payload(schema string, payload interface{}) *PointBuilder {
+	b.s.SignalPayload = NewPayload(schema, payload)
+	return b
+}
+
+// Build validates and returns the point. The time defaults to the current
+// time, and the location is captured when not set and the location capture is
+// enabled (cf. EnableLocationCapture()). The builder can be reused, the points
+// not sharing their tags.
+func (b *PointBuilder) Build() (*Point, error) {
+	if err := validate(&b.s); err != nil {
+		return nil, err
+	}
+	p := b.build()
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	return p, nil
+}
+
+// build returns the point without validating it.
+func (b *PointBuilder) build() *Point {
+	p := Point(b.s)
+	if b.s.Tags != nil {
+		p.Tags = make(map[string]string, len(b.s.Tags))
+		for k, v := range b.s.Tags {
+			p.Tags[k] = v
+		}
+	}
+	if p.Location == nil {
+		if cfg := LocationCaptureConfig(); cfg != nil {
+			p.Location = CaptureLocation(2, cfg)
+		}
+	}
+	return &p
+}
+
+// MetricBuilder builds a metric field by field.
+type MetricBuilder struct {
+	s Signal
+}
+
+// NewMetricBuilder returns a new metric builder.
+func NewMetricBuilder() *MetricBuilder {
+	return &MetricBuilder{s: Signal{Type: "metric"}}
+}
+
+func (b *MetricBuilder) Name(name string) *MetricBuilder {
+	b.s.Name = name
+	return b
+}
+
+func (b *MetricBuilder) Source(source string) *MetricBuilder {
+	b.s.Source = source
+	return b
+}
+
+func (b *MetricBuilder) Time(t time.Time) *MetricBuilder {
+	b.s.Time = t
+	return b
+}
+
+func (b *MetricBuilder) Payload(schema string, payload interface{}) *MetricBuilder {
+	b.s.SignalPayload = NewPayload(schema, payload)
+	return b
+}
+
+// Build validates and returns the metric. The time defaults to the current
+// time.
+func (b *MetricBuilder) Build() (*Metric, error) {
+	if err := validate(&b.s); err != nil {
+		return nil, err
+	}
+	if b.s.Time.IsZero() {
+		b.s.Time = time.Now()
+	}
+	return b.build(), nil
+}
+
+func (b *MetricBuilder) build() *Metric {
+	m := Metric(b.s)
+	return &m
+}
+
+func validate(s *Signal) error {
+	if s.Name == "" {
+		return ErrMissingName
+	}
+	if s.SignalPayload != nil && s.SignalPayload.Schema == "" {
+		return ErrMissingPayloadSchema
+	}
+	if s.SignalContext != nil && s.SignalContext.Schema == "" {
+		return ErrMissingContextSchema
+	}
+	return nil
+}
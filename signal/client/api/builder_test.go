@@ -0,0 +1,77 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointBuilder(t *testing.T) {
+	t.Run("nominal", func(t *testing.T) {
+		now := time.Now()
+		p, err := api.NewPointBuilder().
+			Name("my point").
+			Source("my source").
+			Time(now).
+			Actor("my actor").
+			Trigger("my trigger").
+			Infra("my infra").
+			Tag("team", "security").
+			Context("my context schema", "my context").
+			Payload("my payload schema", "my payload").
+			Build()
+		require.NoError(t, err)
+		expected := api.NewPoint("my point", "my source", now, "my actor", "my trigger", "my infra", nil, api.NewContext("my context schema", "my context"), api.NewPayload("my payload schema", "my payload"))
+		expected.Tags = map[string]string{"team": "security"}
+		require.Equal(t, expected, p)
+	})
+
+	t.Run("default time", func(t *testing.T) {
+		p, err := api.NewPointBuilder().Name("my point").Build()
+		require.NoError(t, err)
+		require.False(t, p.Time.IsZero())
+	})
+
+	t.Run("reused builder", func(t *testing.T) {
+		b := api.NewPointBuilder().Name("my point").Tag("team", "security")
+		p1, err := b.Build()
+		require.NoError(t, err)
+		p2, err := b.Tag("team", "platform").Build()
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"team": "security"}, p1.Tags)
+		require.Equal(t, map[string]string{"team": "platform"}, p2.Tags)
+	})
+
+	for _, tc := range []struct {
+		name     string
+		builder  *api.PointBuilder
+		expected error
+	}{
+		{name: "missing name", builder: api.NewPointBuilder().Source("my source"), expected: api.ErrMissingName},
+		{name: "missing payload schema", builder: api.NewPointBuilder().Name("my point").Payload("", "my payload"), expected: api.ErrMissingPayloadSchema},
+		{name: "missing context schema", builder: api.NewPointBuilder().Name("my point").Context("", "my context"), expected: api.ErrMissingContextSchema},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := tc.builder.Build()
+			require.Equal(t, tc.expected, err)
+			require.Nil(t, p)
+		})
+	}
+}
+
+func TestMetricBuilder(t *testing.T) {
+	now := time.Now()
+	m, err := api.NewMetricBuilder().Name("my metric").Source("my source").Time(now).Payload("my schema", 42).Build()
+	require.NoError(t, err)
+	require.Equal(t, api.NewMetric("my metric", "my source", now, api.NewPayload("my schema", 42)), m)
+
+	_, err = api.NewMetricBuilder().Payload("my schema", 42).Build()
+	require.Equal(t, api.ErrMissingName, err)
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMaxFrames is the maximum number of stack frames of captured
+// locations when the configuration doesn't specify any.
+const DefaultMaxFrames = 32
+
+// sdkPrefix is the prefix of the SDK functions skipped when capturing
+// locations.
+const sdkPrefix = "github.com/sqreen/go-sdk/"
+
+type (
+	// Location is the code location of a signal.
+	Location struct {
+		Stack       []Frame `json:"stack"`
+		GoroutineID uint64  `json:"goroutine_id,omitempty"`
+	}
+
+	// Frame is a stack frame.
+	Frame struct {
+		Function string `json:"function"`
+		Package  string `json:"package,omitempty"`
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+	}
+
+	// LocationConfig is the configuration of the capture of locations. Default
+	// values are used for zero fields.
+	LocationConfig struct {
+		// MaxFrames is the maximum number of stack frames.
+		MaxFrames int
+		// ModulePrefixes are the package path prefixes of the frames to keep,
+		// such as the module path of the application. Every frame is kept when
+		// empty.
+		ModulePrefixes []string
+	}
+)
+
+var locationConfig atomic.Value // *LocationConfig
+
+// EnableLocationCapture enables the capture of the location of the points
+// created by NewPoint() without location. A nil configuration disables it.
+func EnableLocationCapture(cfg *LocationConfig) {
+	locationConfig.Store(cfg)
+}
+
+// LocationCaptureConfig returns the configuration of the location capture,
+// nil when disabled (cf. EnableLocationCapture()).
+func LocationCaptureConfig() *LocationConfig {
+	cfg, _ := locationConfig.Load().(*LocationConfig)
+	return cfg
+}
+
+// CaptureLocation returns the location of the caller, skip being the number of
+// stack frames to skip as in runtime.Caller(). The frames of the SDK and of
+// the runtime are skipped. When called while panicking, the stack starts at
+// the panic site. The given configuration can be nil to use the defaults.
+func CaptureLocation(skip int, cfg *LocationConfig) *Location {
+	maxFrames := DefaultMaxFrames
+	var prefixes []string
+	if cfg != nil {
+		if cfg.MaxFrames > 0 {
+			maxFrames = cfg.MaxFrames
+		}
+		prefixes = cfg.ModulePrefixes
+	}
+
+	// Frames are skipped by filtering so capture extra frames to still fill
+	// the location.
+	pc := make([]uintptr, maxFrames+32)
+	n := runtime.Callers(skip+2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	var stack []Frame
+	for {
+		f, more := frames.Next()
+		if f.Function == "runtime.gopanic" {
+			// Restart at the panic site
+			stack = stack[:0]
+		} else if pkg := functionPackage(f.Function); keepFrame(pkg, prefixes) {
+			stack = append(stack, Frame{Function: f.Function, Package: pkg, File: f.File, Line: f.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	if len(stack) > maxFrames {
+		stack = stack[:maxFrames]
+	}
+	return &Location{Stack: stack, GoroutineID: goroutineID()}
+}
+
+// LocationOf returns the location of the program counter, such as the one of
+// a slog.Record, having a single frame. It returns nil when pc is zero.
+func LocationOf(pc uintptr) *Location {
+	if pc == 0 {
+		return nil
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	frame := Frame{Function: f.Function, Package: functionPackage(f.Function), File: f.File, Line: f.Line}
+	return &Location{Stack: []Frame{frame}, GoroutineID: goroutineID()}
+}
+
+func keepFrame(pkg string, prefixes []string) bool {
+	if pkg == "runtime" || strings.HasPrefix(pkg, "runtime/") || pkg == "" {
+		return false
+	}
+	// Tests of the SDK packages are not part of the SDK
+	if strings.HasPrefix(pkg, sdkPrefix) && !strings.HasSuffix(pkg, "_test") {
+		return false
+	}
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// functionPackage returns the package path of a function name such as
+// "github.com/org/repo/pkg.(*T).Method.func1".
+func functionPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/') + 1
+	dot := strings.IndexByte(function[slash:], '.')
+	if dot < 0 {
+		return function
+	}
+	return function[:slash+dot]
+}
+
+// goroutineID returns the ID of the current goroutine parsed from the first
+// line of its stack trace, such as "goroutine 42 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = b[len("goroutine "):]
+	if i := strings.IndexByte(string(b), ' '); i > 0 {
+		b = b[:i]
+	}
+	id, _ := strconv.ParseUint(string(b), 10, 64)
+	return id
+}
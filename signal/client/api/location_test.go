@@ -0,0 +1,75 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureLocation(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		loc := api.CaptureLocation(0, nil)
+		require.NotZero(t, loc.GoroutineID)
+		require.True(t, len(loc.Stack) > 1)
+		require.Equal(t, "github.com/sqreen/go-sdk/signal/client/api_test.TestCaptureLocation.func1", loc.Stack[0].Function)
+		require.Equal(t, "github.com/sqreen/go-sdk/signal/client/api_test", loc.Stack[0].Package)
+		require.Contains(t, loc.Stack[0].File, "location_test.go")
+		require.NotZero(t, loc.Stack[0].Line)
+		for _, f := range loc.Stack {
+			require.NotEqual(t, "runtime", f.Package)
+		}
+	})
+
+	t.Run("max frames", func(t *testing.T) {
+		loc := api.CaptureLocation(0, &api.LocationConfig{MaxFrames: 1})
+		require.Len(t, loc.Stack, 1)
+	})
+
+	t.Run("module prefixes", func(t *testing.T) {
+		loc := api.CaptureLocation(0, &api.LocationConfig{ModulePrefixes: []string{"testing"}})
+		require.NotEmpty(t, loc.Stack)
+		for _, f := range loc.Stack {
+			require.Equal(t, "testing", f.Package)
+		}
+
+		loc = api.CaptureLocation(0, &api.LocationConfig{ModulePrefixes: []string{"example.com/"}})
+		require.Empty(t, loc.Stack)
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var loc *api.Location
+		func() {
+			defer func() {
+				recover()
+				loc = api.CaptureLocation(0, nil)
+			}()
+			var m map[string]int
+			m["a"]++
+		}()
+		// The stack starts at the panic site, not in the deferred function
+		require.Equal(t, "github.com/sqreen/go-sdk/signal/client/api_test.TestCaptureLocation.func4.1", loc.Stack[0].Function)
+	})
+}
+
+func TestNewPointLocation(t *testing.T) {
+	p := api.NewPoint("name", "source", time.Now(), nil, nil, nil, nil, nil, nil)
+	require.Nil(t, p.Location)
+
+	api.EnableLocationCapture(&api.LocationConfig{MaxFrames: 2})
+	defer api.EnableLocationCapture(nil)
+
+	p = api.NewPoint("name", "source", time.Now(), nil, nil, nil, nil, nil, nil)
+	require.NotNil(t, p.Location)
+	require.Len(t, p.Location.Stack, 2)
+	require.Equal(t, "github.com/sqreen/go-sdk/signal/client/api_test.TestNewPointLocation", p.Location.Stack[0].Function)
+
+	loc := &api.Location{}
+	p = api.NewPoint("name", "source", time.Now(), nil, nil, nil, loc, nil, nil)
+	require.Same(t, loc, p.Location)
+}
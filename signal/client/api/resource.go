@@ -0,0 +1,148 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api
+
+import "reflect"
+
+// Tags of the resource fields.
+const (
+	ServiceNameTag    = "service.name"
+	ServiceVersionTag = "service.version"
+	EnvironmentTag    = "deployment.environment"
+	RegionTag         = "cloud.region"
+)
+
+// Resource describes the service emitting the signals. Its fields are merged
+// into the signals by Apply().
+type Resource struct {
+	// Service is the service name, used as the source of the signals without
+	// one.
+	Service     string
+	Version     string
+	Environment string
+	Region      string
+	// Tags are custom tags added to the signals.
+	Tags map[string]string
+}
+
+// Apply returns a copy of the signal with the resource merged into it, leaving
+// the given signal untouched. The fields already set by the signal take
+// precedence: the resource service is only used as the source of signals
+// without one, and only the tags the signal doesn't have are added. Trace data
+// signals are not modified by the resource, but their fields equal to the
+// trace ones are factored into the trace (cf. Trace.Factor()).
+func (r *Resource) Apply(s SignalFace) SignalFace {
+	if r == nil {
+		return s
+	}
+	cp := Copy(s)
+	sig := BaseSignal(cp)
+	if sig == nil {
+		return s
+	}
+	s = cp
+	if sig.Source == "" {
+		sig.Source = r.Service
+	}
+	if tags := r.tags(); len(tags) > 0 {
+		merged := make(map[string]string, len(sig.Tags)+len(tags))
+		for k, v := range tags {
+			merged[k] = v
+		}
+		for k, v := range sig.Tags {
+			merged[k] = v
+		}
+		sig.Tags = merged
+	}
+	if t := TraceOf(s); t != nil {
+		t.Data = copyData(t.Data)
+		t.Factor()
+	}
+	return s
+}
+
+// copyData returns a copy of the trace data signals, along with their tags, so
+// that they can be factored without modifying the original ones.
+func copyData(data []*Signal) []*Signal {
+	if data == nil {
+		return nil
+	}
+	cp := make([]*Signal, len(data))
+	for i, s := range data {
+		if s == nil {
+			continue
+		}
+		c := *s
+		if s.Tags != nil {
+			c.Tags = make(map[string]string, len(s.Tags))
+			for k, v := range s.Tags {
+				c.Tags[k] = v
+			}
+		}
+		cp[i] = &c
+	}
+	return cp
+}
+
+// tags returns the custom tags along with the tags of the resource fields.
+func (r *Resource) tags() map[string]string {
+	tags := make(map[string]string, len(r.Tags)+4)
+	for k, v := range r.Tags {
+		tags[k] = v
+	}
+	for k, v := range map[string]string{
+		ServiceNameTag:    r.Service,
+		ServiceVersionTag: r.Version,
+		EnvironmentTag:    r.Environment,
+		RegionTag:         r.Region,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+	return tags
+}
+
+// Factor removes from the trace data signals the fields having the same value
+// as the trace ones: the source, actor, location infra and tags.
+func (t *Trace) Factor() {
+	for _, s := range t.Data {
+		if s == nil {
+			continue
+		}
+		if s.Source == t.Source {
+			s.Source = ""
+		}
+		if s.Actor != nil && reflect.DeepEqual(s.Actor, t.Actor) {
+			s.Actor = nil
+		}
+		if s.LocationInfra != nil && reflect.DeepEqual(s.LocationInfra, t.LocationInfra) {
+			s.LocationInfra = nil
+		}
+		for k, v := range s.Tags {
+			if tv, exists := t.Tags[k]; exists && tv == v {
+				delete(s.Tags, k)
+			}
+		}
+		if len(s.Tags) == 0 {
+			s.Tags = nil
+		}
+	}
+}
+
+var traceType = reflect.TypeOf((*Trace)(nil))
+
+// TraceOf returns the trace s is, including the trace types defined from
+// Trace, or nil when s is not a pointer to a trace.
+func TraceOf(s SignalFace) *Trace {
+	if t, ok := s.(*Trace); ok {
+		return t
+	}
+	v := reflect.ValueOf(s)
+	if v.Kind() == reflect.Ptr && v.Type().ConvertibleTo(traceType) {
+		return v.Convert(traceType).Interface().(*Trace)
+	}
+	return nil
+}
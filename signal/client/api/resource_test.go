@@ -0,0 +1,105 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package api_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResource(t *testing.T) {
+	resource := &api.Resource{
+		Service:     "my service",
+		Version:     "v1.2.3",
+		Environment: "production",
+		Tags:        map[string]string{"team": "security", "region": "custom"},
+	}
+
+	t.Run("point", func(t *testing.T) {
+		p := api.NewPoint("my point", "", time.Now(), nil, nil, nil, nil, nil, nil)
+		merged := resource.Apply(p).(*api.Point)
+		require.Equal(t, "my service", merged.Source)
+		require.Equal(t, map[string]string{
+			api.ServiceNameTag:    "my service",
+			api.ServiceVersionTag: "v1.2.3",
+			api.EnvironmentTag:    "production",
+			"team":                "security",
+			"region":              "custom",
+		}, merged.Tags)
+
+		// The signal is left untouched
+		require.Empty(t, p.Source)
+		require.Nil(t, p.Tags)
+	})
+
+	t.Run("signal fields take precedence", func(t *testing.T) {
+		p := api.NewPoint("my point", "my source", time.Now(), nil, nil, nil, nil, nil, nil)
+		p.Tags = map[string]string{"team": "backend"}
+		merged := resource.Apply(p).(*api.Point)
+		require.Equal(t, "my source", merged.Source)
+		require.Equal(t, "backend", merged.Tags["team"])
+		require.Equal(t, "production", merged.Tags[api.EnvironmentTag])
+		require.Equal(t, map[string]string{"team": "backend"}, p.Tags)
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		actor := sqhttp.NewActor([]string{"1.2.3.4"}, "ua", nil)
+		data := []*api.Signal{
+			(*api.Signal)(api.NewPoint("factored", "my service", time.Now(), sqhttp.NewActor([]string{"1.2.3.4"}, "ua", nil), nil, "my infra", nil, nil, nil)),
+			(*api.Signal)(api.NewPoint("kept", "other source", time.Now(), nil, nil, "other infra", nil, nil, nil)),
+		}
+		data[0].Tags = map[string]string{"team": "security"}
+		data[1].Tags = map[string]string{"team": "other"}
+		tr := sqhttp.NewTrace("", time.Now(), actor, "my infra", &sqhttp.Context{}, data)
+
+		merged := resource.Apply(tr).(*sqhttp.Trace)
+		require.Equal(t, "my service", merged.Source)
+		require.Equal(t, "production", merged.Tags[api.EnvironmentTag])
+
+		require.Empty(t, merged.Data[0].Source)
+		require.Nil(t, merged.Data[0].Actor)
+		require.Nil(t, merged.Data[0].LocationInfra)
+		require.Nil(t, merged.Data[0].Tags)
+
+		require.Equal(t, "other source", merged.Data[1].Source)
+		require.Equal(t, "other infra", merged.Data[1].LocationInfra)
+		require.Equal(t, map[string]string{"team": "other"}, merged.Data[1].Tags)
+
+		// The trace and its data are left untouched
+		require.Empty(t, tr.Source)
+		require.Nil(t, tr.Tags)
+		require.Same(t, data[0], tr.Data[0])
+		require.Equal(t, "my service", data[0].Source)
+		require.Equal(t, "my infra", data[0].LocationInfra)
+		require.Equal(t, map[string]string{"team": "security"}, data[0].Tags)
+	})
+
+	t.Run("value signal", func(t *testing.T) {
+		p := *api.NewPoint("my point", "", time.Now(), nil, nil, nil, nil, nil, nil)
+		merged, ok := resource.Apply(p).(*api.Point)
+		require.True(t, ok)
+		require.Equal(t, "my service", merged.Source)
+		require.Equal(t, "production", merged.Tags[api.EnvironmentTag])
+	})
+
+	t.Run("nil resource", func(t *testing.T) {
+		var resource *api.Resource
+		p := api.NewPoint("my point", "", time.Now(), nil, nil, nil, nil, nil, nil)
+		require.Same(t, p, resource.Apply(p))
+	})
+}
+
+func TestTraceOf(t *testing.T) {
+	tr := sqhttp.NewTrace("", time.Now(), nil, nil, &sqhttp.Context{}, nil)
+	require.Same(t, (*api.Trace)(tr), api.TraceOf(tr))
+	apiTrace := &api.Trace{}
+	require.Same(t, apiTrace, api.TraceOf(apiTrace))
+	require.Nil(t, api.TraceOf(api.NewPoint("", "", time.Now(), nil, nil, nil, nil, nil, nil)))
+	require.Nil(t, api.TraceOf(api.Trace{}))
+}
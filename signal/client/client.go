@@ -15,23 +15,78 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/infra"
+	"github.com/sqreen/go-sdk/signal/scrub"
 )
 
 const (
 	DefaultBaseURL = "https://ingestion.sqreen.com/"
 )
 
+// DefaultUserAgent is the User-Agent header of the requests of the clients not
+// having one, identifying the SDK and its version.
+var DefaultUserAgent = userAgent(infra.SDKVersion())
+
+func userAgent(version string) string {
+	if version == "" {
+		version = "unknown"
+	}
+	return "sqreen-go-sdk/" + version
+}
+
 type Client struct {
 	BaseURL *url.URL
-	Logger  DebugLogger
-	client  *http.Client
-	token   string
+	// Logger is the logger of the client, used when LeveledLogger is nil and
+	// logging every level with Debugf().
+	Logger DebugLogger
+	// LeveledLogger is the leveled logger of the client (cf. NewSlogLogger()
+	// and NewStdLogger()).
+	LeveledLogger LeveledLogger
+	// Resource is merged into the signals sent by the client (cf.
+	// api.Resource.Apply()).
+	Resource *api.Resource
+	// Scrubber scrubs the payload and context of the signals sent by the
+	// client when not nil, whatever their origin. The signals are left
+	// untouched, scrubbed copies being sent instead.
+	Scrubber *scrub.Scrubber
+	// Observer is notified of the client activity when not nil.
+	Observer Observer
+	// UserAgent is the User-Agent header of the requests. DefaultUserAgent is
+	// used when empty.
+	UserAgent string
+	// Headers are extra headers set to every request, such as tenant or region
+	// headers.
+	Headers http.Header
+	// Interceptors intercept the requests, in order before sending them and
+	// in reverse order once done.
+	Interceptors []Interceptor
+
+	client *http.Client
+	token  string
 }
 
 type DebugLogger interface {
 	Debugf(format string, v ...interface{})
 }
 
+// Observer is notified of the activity of the client and of its exporters, for
+// instance to monitor the SDK health. Its methods must be safe for concurrent
+// use.
+type Observer interface {
+	// ObserveRequest is called once the request to the API endpoint is done,
+	// with its duration and error.
+	ObserveRequest(endpoint string, d time.Duration, err error)
+	// ObserveRetry is called when a failed request to the API endpoint is
+	// retried.
+	ObserveRetry(endpoint string)
+	// ObserveDrop is called when signals are dropped.
+	ObserveDrop(n int)
+}
+
 func NewClient(client *http.Client, token string) *Client {
 	if client == nil {
 		client = &http.Client{}
@@ -73,20 +128,54 @@ func (c *Client) newRequest(method, urlStr string, reqBody interface{}) (*http.R
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "application/json")
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range c.Headers {
+		req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
 	return req, nil
 }
 
-func (c *Client) do(ctx context.Context, req *http.Request, respBody interface{}) error {
+func (c *Client) do(ctx context.Context, req *http.Request, respBody interface{}) (err error) {
 	if ctx == nil {
 		return errors.New("context must be non-nil")
 	}
 
+	if c.Observer != nil {
+		endpoint := c.endpoint(req)
+		start := time.Now()
+		defer func() {
+			c.Observer.ObserveRequest(endpoint, time.Since(start), err)
+		}()
+	}
+
 	req = req.WithContext(ctx)
 	req.Header.Set("X-Session-Key", c.token)
 
-	c.debugf("sending request\n%s\n", (*httpRequestStringer)(req))
+	for n, i := range c.Interceptors {
+		if err := i.BeforeSend(req); err != nil {
+			// Only the interceptors having intercepted the request are done
+			for j := n - 1; j >= 0; j-- {
+				c.Interceptors[j].AfterReceive(req, nil, err)
+			}
+			return err
+		}
+	}
+
+	c.logger().Debug("sending request", "request", (*httpRequestStringer)(req))
 
 	resp, err := c.client.Do(req)
+	if len(c.Interceptors) > 0 {
+		// Deferred first to run once the response body is drained and closed
+		defer func() {
+			for i := len(c.Interceptors) - 1; i >= 0; i-- {
+				c.Interceptors[i].AfterReceive(req, resp, err)
+			}
+		}()
+	}
 	if err != nil {
 		return err
 	}
@@ -97,10 +186,13 @@ func (c *Client) do(ctx context.Context, req *http.Request, respBody interface{}
 		_ = resp.Body.Close()
 	}()
 
-	c.debugf("received response\n%s\n", (*httpResponseStringer)(resp))
+	c.logger().Debug("received response", "response", (*httpResponseStringer)(resp))
 
 	err = checkResponse(resp)
 	if err != nil {
+		if _, ok := err.(AuthTokenError); ok {
+			c.logger().Error("could not authenticate to the api", "endpoint", c.endpoint(req), "error", err)
+		}
 		return err
 	}
 
@@ -114,11 +206,21 @@ func (c *Client) do(ctx context.Context, req *http.Request, respBody interface{}
 	return nil
 }
 
-func (c *Client) debugf(fmt string, args ...interface{}) {
-	if c.Logger == nil {
-		return
+// endpoint returns the API endpoint of the request, ie. its path relative to
+// the base URL.
+func (c *Client) endpoint(req *http.Request) string {
+	return strings.TrimLeft(strings.TrimPrefix(req.URL.Path, c.BaseURL.Path), "/")
+}
+
+func (c *Client) logger() LeveledLogger {
+	switch {
+	case c.LeveledLogger != nil:
+		return c.LeveledLogger
+	case c.Logger != nil:
+		return debugLogger{c.Logger}
+	default:
+		return nopLogger{}
 	}
-	c.Logger.Debugf(fmt, args...)
 }
 
 type (
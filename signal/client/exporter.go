@@ -0,0 +1,306 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/infra"
+)
+
+// Exporter is the interface of signal exporters. Export must not block the
+// caller and must be safe for concurrent use.
+type Exporter interface {
+	Export(s api.SignalFace)
+}
+
+// MultiExporter is an Exporter exporting the signals with every exporter of
+// the list.
+type MultiExporter []Exporter
+
+func (m MultiExporter) Export(s api.SignalFace) {
+	for _, e := range m {
+		e.Export(s)
+	}
+}
+
+const (
+	DefaultMaxBatchSize = 100
+	DefaultMaxQueueLen  = 10000
+	DefaultFlushPeriod  = 10 * time.Second
+	DefaultRetryDelay   = time.Second
+	DefaultSendTimeout  = 30 * time.Second
+)
+
+// BatchConfig is the configuration of a BatchExporter. Default values are used
+// for zero fields, retries being disabled by default.
+type BatchConfig struct {
+	// MaxBatchSize is the maximum number of signals per batch.
+	MaxBatchSize int
+	// MaxQueueLen is the maximum number of signals waiting to be sent. Signals
+	// exported while the queue is full are dropped.
+	MaxQueueLen int
+	// FlushPeriod is the maximum duration a signal waits in the queue before
+	// being sent.
+	FlushPeriod time.Duration
+	// MaxRetries is the maximum number of times a batch failing to be sent is
+	// retried. Batches rejected by the API, because of invalid signals or
+	// credentials, are not retried.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry, doubled at every retry.
+	RetryDelay time.Duration
+	// SendTimeout is the maximum duration of every attempt to send a batch.
+	SendTimeout time.Duration
+	// Infra is the location infra set to the exported signals not having
+	// one. infra.Default() is used when nil.
+	Infra interface{}
+}
+
+// BatchExporter is an Exporter sending the signals in batches using the signal
+// service of the client.
+type BatchExporter struct {
+	client   *Client
+	cfg      BatchConfig
+	queue    chan api.SignalFace
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	dropped  uint64
+	// unreported is the number of signals dropped by Export() since the last
+	// drop report.
+	unreported uint64
+	// pending is the batch being built when the exporter was stopped.
+	pending api.Batch
+}
+
+// NewBatchExporter returns a new batch exporter sending the signals using the
+// given client, until Stop is called.
+func NewBatchExporter(client *Client, cfg BatchConfig) *BatchExporter {
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if cfg.MaxQueueLen <= 0 {
+		cfg.MaxQueueLen = DefaultMaxQueueLen
+	}
+	if cfg.FlushPeriod <= 0 {
+		cfg.FlushPeriod = DefaultFlushPeriod
+	}
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+	if cfg.SendTimeout <= 0 {
+		cfg.SendTimeout = DefaultSendTimeout
+	}
+	if cfg.Infra == nil {
+		cfg.Infra = infra.Default()
+	}
+	e := &BatchExporter{
+		client:  client,
+		cfg:     cfg,
+		queue:   make(chan api.SignalFace, cfg.MaxQueueLen),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export adds the signal to the queue of signals to send. The signal is dropped
+// when the queue is full or the exporter is stopped. Signals without location
+// infra are sent with the one of the exporter, the signal itself being left
+// untouched.
+func (e *BatchExporter) Export(s api.SignalFace) {
+	select {
+	case <-e.done:
+		e.drop()
+		return
+	default:
+	}
+
+	select {
+	case e.queue <- s:
+	default:
+		e.drop()
+	}
+}
+
+// Dropped returns the number of signals dropped so far, including the signals
+// of the batches that couldn't be sent.
+func (e *BatchExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// QueueLen returns the number of signals waiting in the queue.
+func (e *BatchExporter) QueueLen() int {
+	return len(e.queue)
+}
+
+func (e *BatchExporter) drop() {
+	atomic.AddUint64(&e.unreported, 1)
+	e.dropN(1)
+}
+
+// reportDrops logs the number of signals dropped by Export() since the last
+// report, so that a full queue doesn't log every dropped signal.
+func (e *BatchExporter) reportDrops() {
+	if n := atomic.SwapUint64(&e.unreported, 0); n > 0 {
+		e.client.logger().Warn("signals dropped because the exporter queue is full or stopped", "count", n)
+	}
+}
+
+func (e *BatchExporter) dropN(n int) {
+	atomic.AddUint64(&e.dropped, uint64(n))
+	if o := e.client.Observer; o != nil {
+		o.ObserveDrop(n)
+	}
+}
+
+// Stop stops the exporter and sends the remaining queued signals. The context
+// allows to limit the time spent sending them. It must not be called
+// concurrently.
+func (e *BatchExporter) Stop(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.done) })
+	select {
+	case <-e.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	e.reportDrops()
+	batch := e.pending
+	e.pending = nil
+	for {
+		batch = e.dequeue(batch)
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := e.client.SignalService().SendBatch(ctx, batch); err != nil {
+			e.client.logger().Error("could not send the batch, dropping its signals", "count", len(batch), "error", err)
+			e.dropN(len(batch))
+			return err
+		}
+		batch = nil
+	}
+}
+
+func (e *BatchExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.cfg.FlushPeriod)
+	defer ticker.Stop()
+
+	batch := make(api.Batch, 0, e.cfg.MaxBatchSize)
+	for {
+		select {
+		case <-e.done:
+			// The pending batch is sent by Stop() along with the remaining
+			// queued signals.
+			e.pending = batch
+			return
+
+		case s := <-e.queue:
+			batch = append(batch, e.withInfra(s))
+			if len(batch) < e.cfg.MaxBatchSize {
+				continue
+			}
+
+		case <-ticker.C:
+		}
+
+		e.reportDrops()
+		if !e.send(batch) {
+			// Stopped while waiting to retry: the batch is sent by Stop().
+			e.pending = batch
+			return
+		}
+		batch = make(api.Batch, 0, e.cfg.MaxBatchSize)
+	}
+}
+
+// send sends the batch, retrying according to the configuration. It returns
+// false when the exporter was stopped while waiting to retry.
+func (e *BatchExporter) send(batch api.Batch) bool {
+	if len(batch) == 0 {
+		return true
+	}
+	delay := e.cfg.RetryDelay
+	for retry := 0; ; retry++ {
+		ctx, cancel := context.WithTimeout(context.Background(), e.cfg.SendTimeout)
+		err := e.client.SignalService().SendBatch(ctx, batch)
+		cancel()
+		if err == nil {
+			return true
+		}
+		if retry >= e.cfg.MaxRetries || !retryable(err) {
+			e.client.logger().Error("could not send the batch, dropping its signals", "count", len(batch), "error", err)
+			e.dropN(len(batch))
+			return true
+		}
+		e.client.logger().Warn("could not send the batch, retrying", "count", len(batch), "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-e.done:
+			timer.Stop()
+			return false
+		}
+		delay *= 2
+		if o := e.client.Observer; o != nil {
+			o.ObserveRetry("batches")
+		}
+	}
+}
+
+// retryable returns true when the request error may be temporary: network
+// errors, rate limiting and server errors.
+func retryable(err error) bool {
+	var (
+		authErr    AuthTokenError
+		invalidErr InvalidSignalError
+		apiErr     APIError
+	)
+	switch {
+	case errors.As(err, &authErr), errors.As(err, &invalidErr):
+		return false
+	case errors.As(err, &apiErr):
+		c := apiErr.Response.StatusCode
+		return c == http.StatusTooManyRequests || c >= 500
+	default:
+		return true
+	}
+}
+
+// withInfra returns a copy of the signal having the location infra of the
+// exporter when it doesn't have one, or the signal itself.
+func (e *BatchExporter) withInfra(s api.SignalFace) api.SignalFace {
+	if sig := api.BaseSignal(s); sig != nil && sig.LocationInfra != nil {
+		return s
+	}
+	cp := api.Copy(s)
+	sig := api.BaseSignal(cp)
+	if sig == nil || sig.LocationInfra != nil {
+		return s
+	}
+	sig.LocationInfra = e.cfg.Infra
+	return cp
+}
+
+func (e *BatchExporter) dequeue(batch api.Batch) api.Batch {
+	for len(batch) < e.cfg.MaxBatchSize {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, e.withInfra(s))
+		default:
+			return batch
+		}
+	}
+	return batch
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/infra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchExporter(t *testing.T) {
+	newServer := func(t *testing.T) (*client.Client, *batchRecorder) {
+		var rec batchRecorder
+		srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/batches", r.RequestURI)
+			var batch []json.RawMessage
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+			rec.add(batch)
+		}))
+		t.Cleanup(srv.Close)
+		c := client.NewClient(srv.Client(), "")
+		baseURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		c.BaseURL = baseURL
+		return c, &rec
+	}
+
+	t.Run("batch size", func(t *testing.T) {
+		c, rec := newServer(t)
+		e := client.NewBatchExporter(c, client.BatchConfig{MaxBatchSize: 2, FlushPeriod: time.Hour})
+		for i := 0; i < 5; i++ {
+			e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+		}
+		require.NoError(t, e.Stop(context.Background()))
+		require.Equal(t, []int{2, 2, 1}, rec.sizes())
+		require.Equal(t, uint64(0), e.Dropped())
+
+		t.Run("export after stop", func(t *testing.T) {
+			e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+			require.Equal(t, uint64(1), e.Dropped())
+		})
+	})
+
+	t.Run("flush period", func(t *testing.T) {
+		c, rec := newServer(t)
+		e := client.NewBatchExporter(c, client.BatchConfig{FlushPeriod: time.Millisecond})
+		defer e.Stop(context.Background())
+		e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+		require.Eventually(t, func() bool {
+			return len(rec.sizes()) == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("location infra", func(t *testing.T) {
+		c, rec := newServer(t)
+		e := client.NewBatchExporter(c, client.BatchConfig{Infra: "my infra", FlushPeriod: time.Hour})
+
+		point := api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil)
+		e.Export(point)
+		trace := sqhttp.NewTrace("test", time.Now(), nil, nil, &sqhttp.Context{}, nil)
+		e.Export(trace)
+		e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, "caller infra", nil, nil, nil))
+		e.Export(*api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+		require.NoError(t, e.Stop(context.Background()))
+
+		require.Equal(t, []interface{}{"my infra", "my infra", "caller infra", "my infra"}, rec.infras(t))
+		// The exported signals are left untouched
+		require.Nil(t, point.LocationInfra)
+		require.Nil(t, trace.LocationInfra)
+
+		t.Run("default", func(t *testing.T) {
+			c, rec := newServer(t)
+			e := client.NewBatchExporter(c, client.BatchConfig{FlushPeriod: time.Hour})
+			metric := api.NewMetric("my metric", "test", time.Now(), nil)
+			e.Export(metric)
+			require.NoError(t, e.Stop(context.Background()))
+			require.Nil(t, metric.LocationInfra)
+			buf, err := json.Marshal(infra.Default())
+			require.NoError(t, err)
+			var expected interface{}
+			require.NoError(t, json.Unmarshal(buf, &expected))
+			require.Equal(t, []interface{}{expected}, rec.infras(t))
+		})
+	})
+}
+
+func TestBatchExporterRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		requests int32
+	}{
+		{"server error", http.StatusInternalServerError, 3},
+		{"rate limited", http.StatusTooManyRequests, 3},
+		{"invalid signal", http.StatusUnprocessableEntity, 1},
+		{"unauthorized", http.StatusUnauthorized, 1},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+			c := client.NewClient(srv.Client(), "")
+			baseURL, err := url.Parse(srv.URL)
+			require.NoError(t, err)
+			c.BaseURL = baseURL
+
+			e := client.NewBatchExporter(c, client.BatchConfig{MaxBatchSize: 1, MaxRetries: 2, RetryDelay: time.Millisecond})
+			defer e.Stop(context.Background())
+			e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+			require.Eventually(t, func() bool {
+				return e.Dropped() == 1
+			}, time.Second, time.Millisecond)
+			require.Equal(t, tc.requests, atomic.LoadInt32(&requests))
+		})
+	}
+
+	t.Run("wrapped error", func(t *testing.T) {
+		var attempts int32
+		c := client.NewClient(http.DefaultClient, "")
+		c.Interceptors = []client.Interceptor{client.InterceptorFuncs{
+			BeforeSendFunc: func(*http.Request) error {
+				atomic.AddInt32(&attempts, 1)
+				return fmt.Errorf("intercepted: %w", client.InvalidSignalError{})
+			},
+		}}
+		e := client.NewBatchExporter(c, client.BatchConfig{MaxBatchSize: 1, MaxRetries: 2, RetryDelay: time.Millisecond})
+		defer e.Stop(context.Background())
+		e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+		require.Eventually(t, func() bool {
+			return e.Dropped() == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+	})
+}
+
+func TestBatchExporterSendTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	defer srv.Close()
+	defer close(unblock)
+	c := client.NewClient(srv.Client(), "")
+	baseURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	c.BaseURL = baseURL
+
+	e := client.NewBatchExporter(c, client.BatchConfig{MaxBatchSize: 1, SendTimeout: time.Millisecond})
+	defer e.Stop(context.Background())
+	e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	require.Eventually(t, func() bool {
+		return e.Dropped() == 1
+	}, time.Second, time.Millisecond)
+}
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batch   []int
+	signals []json.RawMessage
+}
+
+func (r *batchRecorder) add(batch []json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batch = append(r.batch, len(batch))
+	r.signals = append(r.signals, batch...)
+}
+
+// infras returns the location infras of the signals received so far.
+func (r *batchRecorder) infras(t *testing.T) []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infras := make([]interface{}, 0, len(r.signals))
+	for _, s := range r.signals {
+		var sig struct {
+			LocationInfra interface{} `json:"location_infra"`
+		}
+		require.NoError(t, json.Unmarshal(s, &sig))
+		infras = append(infras, sig.LocationInfra)
+	}
+	return infras
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.batch...)
+}
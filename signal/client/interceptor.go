@@ -0,0 +1,40 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client
+
+import "net/http"
+
+// Interceptor intercepts the requests of the client, for instance to sign
+// them or to measure their duration (cf. Client.Interceptors).
+type Interceptor interface {
+	// BeforeSend is called before sending the request, once every header of
+	// the client is set. The request can be modified, and returning an error
+	// aborts it: AfterReceive is then only called for the previous
+	// interceptors, with the error.
+	BeforeSend(req *http.Request) error
+	// AfterReceive is called once the request is done, with its response and
+	// error. The response is nil when the request failed before receiving
+	// it, and its body is already consumed.
+	AfterReceive(req *http.Request, resp *http.Response, err error)
+}
+
+// InterceptorFuncs is an Interceptor calling its functions when not nil.
+type InterceptorFuncs struct {
+	BeforeSendFunc   func(req *http.Request) error
+	AfterReceiveFunc func(req *http.Request, resp *http.Response, err error)
+}
+
+func (f InterceptorFuncs) BeforeSend(req *http.Request) error {
+	if f.BeforeSendFunc == nil {
+		return nil
+	}
+	return f.BeforeSendFunc(req)
+}
+
+func (f InterceptorFuncs) AfterReceive(req *http.Request, resp *http.Response, err error) {
+	if f.AfterReceiveFunc != nil {
+		f.AfterReceiveFunc(req, resp, err)
+	}
+}
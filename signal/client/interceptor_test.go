@@ -0,0 +1,148 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	var received http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	newClient := func(t *testing.T) *client.Client {
+		c := client.NewClient(srv.Client(), "my token")
+		baseURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		c.BaseURL = baseURL
+		return c
+	}
+	send := func(c *client.Client) error {
+		point := api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil)
+		return c.SignalService().SendSignal(context.Background(), (*api.Signal)(point))
+	}
+
+	t.Run("default headers", func(t *testing.T) {
+		received = nil
+		require.NoError(t, send(newClient(t)))
+		require.Equal(t, client.DefaultUserAgent, received.Get("User-Agent"))
+		require.True(t, strings.HasPrefix(client.DefaultUserAgent, "sqreen-go-sdk/"))
+		require.Equal(t, "my token", received.Get("X-Session-Key"))
+	})
+
+	t.Run("extra headers", func(t *testing.T) {
+		received = nil
+		c := newClient(t)
+		c.UserAgent = "my agent"
+		c.Headers = http.Header{"x-tenant": {"my tenant"}, "X-Region": {"eu"}}
+		require.NoError(t, send(c))
+		require.Equal(t, "my agent", received.Get("User-Agent"))
+		require.Equal(t, "my tenant", received.Get("X-Tenant"))
+		require.Equal(t, "eu", received.Get("X-Region"))
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		received = nil
+		c := newClient(t)
+		c.Headers = http.Header{"X-Tenant": {"my tenant"}}
+		var calls []string
+		interceptor := func(name string) client.Interceptor {
+			return client.InterceptorFuncs{
+				BeforeSendFunc: func(req *http.Request) error {
+					calls = append(calls, "before "+name)
+					// Every header is set before the interceptors
+					require.Equal(t, "my tenant", req.Header.Get("X-Tenant"))
+					require.Equal(t, "my token", req.Header.Get("X-Session-Key"))
+					req.Header.Add("X-Signature", name)
+					return nil
+				},
+				AfterReceiveFunc: func(req *http.Request, resp *http.Response, err error) {
+					calls = append(calls, "after "+name)
+					require.NoError(t, err)
+					require.Equal(t, http.StatusAccepted, resp.StatusCode)
+				},
+			}
+		}
+		c.Interceptors = []client.Interceptor{interceptor("a"), interceptor("b")}
+		require.NoError(t, send(c))
+		require.Equal(t, []string{"before a", "before b", "after b", "after a"}, calls)
+		require.Equal(t, []string{"a", "b"}, received.Values("X-Signature"))
+		// The client headers are not modified by the interceptors
+		require.Equal(t, http.Header{"X-Tenant": {"my tenant"}}, c.Headers)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		received = nil
+		c := newClient(t)
+		myErr := errors.New("my error")
+		var calls []string
+		var afterErr error
+		c.Interceptors = []client.Interceptor{
+			client.InterceptorFuncs{
+				BeforeSendFunc: func(*http.Request) error {
+					calls = append(calls, "before a")
+					return nil
+				},
+				AfterReceiveFunc: func(_ *http.Request, resp *http.Response, err error) {
+					calls = append(calls, "after a")
+					require.Nil(t, resp)
+					afterErr = err
+				},
+			},
+			client.InterceptorFuncs{
+				BeforeSendFunc: func(*http.Request) error {
+					calls = append(calls, "before b")
+					return myErr
+				},
+				AfterReceiveFunc: func(*http.Request, *http.Response, error) {
+					calls = append(calls, "after b")
+				},
+			},
+			client.InterceptorFuncs{
+				BeforeSendFunc: func(*http.Request) error {
+					calls = append(calls, "before c")
+					return nil
+				},
+			},
+		}
+		require.Equal(t, myErr, send(c))
+		require.Nil(t, received)
+		require.Equal(t, []string{"before a", "before b", "after a"}, calls)
+		require.Equal(t, myErr, afterErr)
+	})
+
+	t.Run("api error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+		c := client.NewClient(srv.Client(), "")
+		c.BaseURL, _ = url.Parse(srv.URL)
+		var afterErr error
+		c.Interceptors = []client.Interceptor{client.InterceptorFuncs{
+			AfterReceiveFunc: func(_ *http.Request, _ *http.Response, err error) {
+				afterErr = err
+			},
+		}}
+		err := send(c)
+		require.IsType(t, client.AuthTokenError{}, err)
+		require.Equal(t, err, afterErr)
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// LeveledLogger is the interface of leveled loggers with structured fields,
+// given as alternating keys and values as in log/slog.
+type LeveledLogger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// NewSlogLogger returns the leveled logger logging with the slog logger, or
+// with slog.Default() when nil.
+func NewSlogLogger(l *slog.Logger) LeveledLogger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l}
+}
+
+type slogLogger struct {
+	l *slog.Logger
+}
+
+func (l slogLogger) Debug(msg string, keyvals ...interface{}) { l.log(slog.LevelDebug, msg, keyvals) }
+func (l slogLogger) Info(msg string, keyvals ...interface{})  { l.log(slog.LevelInfo, msg, keyvals) }
+func (l slogLogger) Warn(msg string, keyvals ...interface{})  { l.log(slog.LevelWarn, msg, keyvals) }
+func (l slogLogger) Error(msg string, keyvals ...interface{}) { l.log(slog.LevelError, msg, keyvals) }
+
+func (l slogLogger) log(level slog.Level, msg string, keyvals []interface{}) {
+	l.l.Log(context.Background(), level, msg, keyvals...)
+}
+
+// NewStdLogger returns the leveled logger logging with the standard logger,
+// or with log.Default() when nil. Messages are prefixed by their level and
+// followed by their fields formatted as key=value pairs.
+func NewStdLogger(l *log.Logger) LeveledLogger {
+	if l == nil {
+		l = log.Default()
+	}
+	return stdLogger{l}
+}
+
+type stdLogger struct {
+	l *log.Logger
+}
+
+func (l stdLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals) }
+func (l stdLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals) }
+func (l stdLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals) }
+func (l stdLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals) }
+
+func (l stdLogger) log(level, msg string, keyvals []interface{}) {
+	l.l.Print(level + " " + formatLog(msg, keyvals))
+}
+
+// debugLogger adapts a DebugLogger into a leveled logger logging every level
+// with Debugf().
+type debugLogger struct {
+	l DebugLogger
+}
+
+func (l debugLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals) }
+func (l debugLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals) }
+func (l debugLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals) }
+func (l debugLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals) }
+
+func (l debugLogger) log(level, msg string, keyvals []interface{}) {
+	l.l.Debugf("%s %s", level, formatLog(msg, keyvals))
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// formatLog returns the message followed by the fields formatted as key=value
+// pairs, a value without key having the key !BADKEY as in log/slog.
+func formatLog(msg string, keyvals []interface{}) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, value := "!BADKEY", keyvals[i]
+		if k, ok := keyvals[i].(string); ok && i+1 < len(keyvals) {
+			key, value = k, keyvals[i+1]
+		} else {
+			i--
+		}
+		fmt.Fprintf(&b, " %s=%v", key, value)
+	}
+	return b.String()
+}
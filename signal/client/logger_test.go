@@ -0,0 +1,140 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggers(t *testing.T) {
+	t.Run("std", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := client.NewStdLogger(log.New(&buf, "", 0))
+		l.Debug("debug")
+		l.Info("info", "key", 42)
+		l.Warn("warn", "a", "b", "c")
+		l.Error("error", 1, 2)
+		require.Equal(t, "DEBUG debug\nINFO info key=42\nWARN warn a=b !BADKEY=c\nERROR error !BADKEY=1 !BADKEY=2\n", buf.String())
+	})
+
+	t.Run("slog", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := client.NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			Level: slog.LevelInfo,
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})))
+		l.Debug("debug")
+		l.Info("info", "key", 42)
+		l.Warn("warn")
+		l.Error("error", "err", "oops")
+		require.Equal(t, "level=INFO msg=info key=42\nlevel=WARN msg=warn\nlevel=ERROR msg=error err=oops\n", buf.String())
+	})
+}
+
+func TestClientLogging(t *testing.T) {
+	newClient := func(t *testing.T, status int) *client.Client {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(status)
+		}))
+		t.Cleanup(srv.Close)
+		c := client.NewClient(srv.Client(), "")
+		baseURL, err := url.Parse(srv.URL)
+		require.NoError(t, err)
+		c.BaseURL = baseURL
+		return c
+	}
+
+	t.Run("debug logger", func(t *testing.T) {
+		c := newClient(t, http.StatusUnauthorized)
+		var logger debugLoggerRecorder
+		c.Logger = &logger
+		err := c.SignalService().SendSignal(context.Background(), (*api.Signal)(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil)))
+		require.Error(t, err)
+		logs := logger.get()
+		require.Len(t, logs, 3)
+		require.True(t, strings.HasPrefix(logs[0], "DEBUG sending request"))
+		require.True(t, strings.HasPrefix(logs[1], "DEBUG received response"))
+		require.Equal(t, "ERROR could not authenticate to the api endpoint=signals error=api error: access token is missing or invalid", logs[2])
+	})
+
+	t.Run("exporter", func(t *testing.T) {
+		c := newClient(t, http.StatusServiceUnavailable)
+		var logger leveledLoggerRecorder
+		c.LeveledLogger = &logger
+		e := client.NewBatchExporter(c, client.BatchConfig{MaxQueueLen: 1, MaxRetries: 1, RetryDelay: time.Millisecond, FlushPeriod: time.Hour})
+		for i := 0; i < 10; i++ {
+			e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+		}
+		require.Error(t, e.Stop(context.Background()))
+		logs := logger.get()
+		// The number of signals dropped by the full queue depends on how fast
+		// the exporter dequeues them.
+		require.Len(t, logs, 2)
+		require.Regexp(t, `^WARN signals dropped because the exporter queue is full or stopped count=\d+$`, logs[0])
+		require.Regexp(t, `^ERROR could not send the batch, dropping its signals count=\d+ error=api error: response with status code 503 Service Unavailable$`, logs[1])
+	})
+}
+
+type debugLoggerRecorder struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (r *debugLoggerRecorder) Debugf(format string, v ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, fmt.Sprintf(format, v...))
+}
+
+func (r *debugLoggerRecorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.logs...)
+}
+
+// leveledLoggerRecorder records the logs but the debug ones.
+type leveledLoggerRecorder struct {
+	debugLoggerRecorder
+}
+
+func (r *leveledLoggerRecorder) Debug(string, ...interface{}) {}
+func (r *leveledLoggerRecorder) Info(msg string, keyvals ...interface{}) {
+	r.log("INFO", msg, keyvals)
+}
+func (r *leveledLoggerRecorder) Warn(msg string, keyvals ...interface{}) {
+	r.log("WARN", msg, keyvals)
+}
+func (r *leveledLoggerRecorder) Error(msg string, keyvals ...interface{}) {
+	r.log("ERROR", msg, keyvals)
+}
+
+func (r *leveledLoggerRecorder) log(level, msg string, keyvals []interface{}) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", level, msg)
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", keyvals[i], keyvals[i+1])
+	}
+	r.Debugf("%s", b.String())
+}
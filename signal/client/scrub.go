@@ -0,0 +1,64 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package client
+
+import (
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/scrub"
+)
+
+// scrubbed returns a copy of the signal whose payload and context, along with
+// the ones of its trace data signals, are scrubbed by the client scrubber.
+// Metrics, whose values are aggregates, are not scrubbed. The signal itself is
+// returned when the client has no scrubber.
+func (c *Client) scrubbed(s api.SignalFace) api.SignalFace {
+	if c.Scrubber == nil {
+		return s
+	}
+	if api.BaseSignal(s) == nil {
+		// Value signals are scrubbed through a pointer to a copy
+		if cp := api.Copy(s); api.BaseSignal(cp) != nil {
+			return c.scrubbed(cp)
+		}
+		return s
+	}
+	if t := api.TraceOf(s); t != nil {
+		cp := *t
+		scrubSignal(&cp.Signal, c.Scrubber)
+		if t.Data != nil {
+			cp.Data = make([]*api.Signal, len(t.Data))
+			for i, sig := range t.Data {
+				if sig == nil {
+					continue
+				}
+				data := *sig
+				scrubSignal(&data, c.Scrubber)
+				cp.Data[i] = &data
+			}
+		}
+		return &cp
+	}
+	switch sig := s.(type) {
+	case *api.Signal:
+		cp := *sig
+		scrubSignal(&cp, c.Scrubber)
+		return &cp
+	case *api.Point:
+		cp := *sig
+		scrubSignal((*api.Signal)(&cp), c.Scrubber)
+		return &cp
+	default:
+		return s
+	}
+}
+
+func scrubSignal(sig *api.Signal, s *scrub.Scrubber) {
+	if p := sig.SignalPayload; p != nil {
+		sig.SignalPayload = api.NewPayload(p.Schema, s.Value("payload", p.Payload, nil))
+	}
+	if c := sig.SignalContext; c != nil {
+		sig.SignalContext = api.NewContext(c.Schema, s.Value("context", c.Context, nil))
+	}
+}
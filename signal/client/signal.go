@@ -20,6 +20,20 @@ func (s *SignalService) SendBatch(ctx context.Context, b api.Batch) error {
 		return errors.New("unexpected empty batch")
 	}
 	c := s.unwrap()
+	if c.Scrubber != nil {
+		scrubbed := make(api.Batch, len(b))
+		for i, signal := range b {
+			scrubbed[i] = c.scrubbed(signal)
+		}
+		b = scrubbed
+	}
+	if c.Resource != nil {
+		merged := make(api.Batch, len(b))
+		for i, signal := range b {
+			merged[i] = c.Resource.Apply(signal)
+		}
+		b = merged
+	}
 	r, err := c.newRequest("POST", "batches", b)
 	if err != nil {
 		return err
@@ -35,7 +49,8 @@ func (s *SignalService) SendTrace(ctx context.Context, trace *api.Trace) error {
 		return errors.New("unexpected empty trace data array")
 	}
 	c := s.unwrap()
-	r, err := c.newRequest("POST", "traces", trace)
+	t := c.Resource.Apply(c.scrubbed(trace))
+	r, err := c.newRequest("POST", "traces", t)
 	if err != nil {
 		return err
 	}
@@ -47,7 +62,8 @@ func (s *SignalService) SendSignal(ctx context.Context, signal *api.Signal) erro
 		return errors.New("unexpected signal argument value `nil`")
 	}
 	c := s.unwrap()
-	r, err := c.newRequest("POST", "signals", signal)
+	sig := c.Resource.Apply(c.scrubbed(signal))
+	r, err := c.newRequest("POST", "signals", sig)
 	if err != nil {
 		return err
 	}
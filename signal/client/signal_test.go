@@ -11,9 +11,11 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/sqreen/go-sdk/signal/client"
 	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/scrub"
 	"github.com/stretchr/testify/require"
 )
 
@@ -210,3 +212,95 @@ func TestSignalService(t *testing.T) {
 		})
 	})
 }
+
+func TestSignalServiceScrubber(t *testing.T) {
+	var received []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+	c := client.NewClient(srv.Client(), "")
+	baseURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	c.BaseURL = baseURL
+	c.Scrubber = scrub.NewDefaultScrubber()
+
+	point := api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil,
+		api.NewContext("my context", map[string]interface{}{"email": "bob@example.com"}),
+		api.NewPayload("my schema", map[string]interface{}{"password": "1234", "user": "bob"}))
+	trace := api.NewTrace("my trace", "test", time.Now(), nil, nil, nil, nil, nil, nil, []*api.Signal{(*api.Signal)(point)})
+
+	err = c.SignalService().SendBatch(context.Background(), api.Batch{point, trace})
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+
+	var sentPoint api.Signal
+	require.NoError(t, json.Unmarshal(received[0], &sentPoint))
+	require.Equal(t, map[string]interface{}{"password": scrub.Redacted, "user": "bob"}, sentPoint.Payload)
+	require.Equal(t, map[string]interface{}{"email": scrub.Redacted}, sentPoint.Context)
+
+	var sentTrace api.Trace
+	require.NoError(t, json.Unmarshal(received[1], &sentTrace))
+	require.Len(t, sentTrace.Data, 1)
+	require.Equal(t, map[string]interface{}{"password": scrub.Redacted, "user": "bob"}, sentTrace.Data[0].Payload)
+
+	// The signals are left untouched
+	require.Equal(t, "1234", point.Payload.(map[string]interface{})["password"])
+	require.Equal(t, "bob@example.com", point.Context.(map[string]interface{})["email"])
+	require.Same(t, (*api.Signal)(point), trace.Data[0])
+
+	t.Run("value signals", func(t *testing.T) {
+		err := c.SignalService().SendBatch(context.Background(), api.Batch{*point, *trace})
+		require.NoError(t, err)
+		require.Len(t, received, 2)
+
+		var sentPoint api.Signal
+		require.NoError(t, json.Unmarshal(received[0], &sentPoint))
+		require.Equal(t, map[string]interface{}{"password": scrub.Redacted, "user": "bob"}, sentPoint.Payload)
+
+		var sentTrace api.Trace
+		require.NoError(t, json.Unmarshal(received[1], &sentTrace))
+		require.Equal(t, map[string]interface{}{"password": scrub.Redacted, "user": "bob"}, sentTrace.Data[0].Payload)
+		require.Equal(t, "1234", point.Payload.(map[string]interface{})["password"])
+	})
+}
+
+func TestSignalServiceResource(t *testing.T) {
+	var received []json.RawMessage
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer srv.Close()
+	c := client.NewClient(srv.Client(), "")
+	baseURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	c.BaseURL = baseURL
+	c.Resource = &api.Resource{Service: "my service", Tags: map[string]string{"team": "security"}}
+
+	point := api.NewPoint("my point", "", time.Now(), nil, nil, nil, nil, nil, nil)
+	data := (*api.Signal)(api.NewPoint("my point", "my service", time.Now(), nil, nil, nil, nil, nil, nil))
+	trace := api.NewTrace("my trace", "", time.Now(), nil, nil, nil, nil, nil, nil, []*api.Signal{data})
+
+	err = c.SignalService().SendBatch(context.Background(), api.Batch{point, trace})
+	require.NoError(t, err)
+	require.Len(t, received, 2)
+
+	tags := map[string]string{"team": "security", api.ServiceNameTag: "my service"}
+	var sentPoint api.Signal
+	require.NoError(t, json.Unmarshal(received[0], &sentPoint))
+	require.Equal(t, "my service", sentPoint.Source)
+	require.Equal(t, tags, sentPoint.Tags)
+
+	var sentTrace api.Trace
+	require.NoError(t, json.Unmarshal(received[1], &sentTrace))
+	require.Equal(t, "my service", sentTrace.Source)
+	require.Equal(t, tags, sentTrace.Tags)
+	require.Len(t, sentTrace.Data, 1)
+	require.Empty(t, sentTrace.Data[0].Source)
+
+	// The signals are left untouched
+	require.Empty(t, point.Source)
+	require.Nil(t, point.Tags)
+	require.Empty(t, trace.Source)
+	require.Equal(t, "my service", data.Source)
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package config loads the SDK configuration from a YAML or JSON file and from
+// SQREEN_* environment variables, and builds the SDK components it configures
+// (cf. NewSDK()).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/scrub"
+	"go.yaml.in/yaml/v3"
+)
+
+// FileEnv is the environment variable of the configuration file path used
+// when Load() is given an empty path.
+const FileEnv = "SQREEN_CONFIG_FILE"
+
+// DefaultTimeout is the timeout of the API requests when the configuration
+// doesn't specify any.
+const DefaultTimeout = 30 * time.Second
+
+// Config is the SDK configuration. Default values are used for zero fields.
+// Environment variables are given along with the fields they set.
+type Config struct {
+	// Token is the API token. SQREEN_TOKEN. Required.
+	Token string `yaml:"token"`
+	// BaseURL is the API base URL. SQREEN_BASE_URL. client.DefaultBaseURL is
+	// used when empty.
+	BaseURL string `yaml:"base_url"`
+	// Timeout is the timeout of the API requests. SQREEN_TIMEOUT.
+	Timeout time.Duration `yaml:"timeout"`
+
+	// Resource describes the service (cf. api.Resource).
+	Service     string `yaml:"service"`     // SQREEN_SERVICE
+	Version     string `yaml:"version"`     // SQREEN_VERSION
+	Environment string `yaml:"environment"` // SQREEN_ENVIRONMENT
+	Region      string `yaml:"region"`      // SQREEN_REGION
+
+	Retry     RetryConfig     `yaml:"retry"`
+	Batch     BatchConfig     `yaml:"batch"`
+	Sampling  SamplingConfig  `yaml:"sampling"`
+	Scrubbing ScrubbingConfig `yaml:"scrubbing"`
+}
+
+// RetryConfig is the retry policy of the batches failing to be sent (cf.
+// client.BatchConfig).
+type RetryConfig struct {
+	// MaxRetries is the maximum number of retries per batch, retries being
+	// disabled when zero. SQREEN_RETRY_MAX_RETRIES.
+	MaxRetries int `yaml:"max_retries"`
+	// Delay is the delay before the first retry. SQREEN_RETRY_DELAY.
+	Delay time.Duration `yaml:"delay"`
+}
+
+// BatchConfig is the batching configuration of the exporter (cf.
+// client.BatchConfig).
+type BatchConfig struct {
+	MaxSize     int           `yaml:"max_size"`      // SQREEN_BATCH_MAX_SIZE
+	MaxQueueLen int           `yaml:"max_queue_len"` // SQREEN_BATCH_MAX_QUEUE_LEN
+	FlushPeriod time.Duration `yaml:"flush_period"`  // SQREEN_BATCH_FLUSH_PERIOD
+}
+
+// SamplingConfig is the sampling configuration of the HTTP traces. Every trace
+// is sampled when empty.
+type SamplingConfig struct {
+	// Rate is the probability between 0 and 1 of sampling a trace.
+	// SQREEN_SAMPLING_RATE.
+	Rate *float64 `yaml:"rate"`
+	// MaxPerSecond is the maximum number of sampled traces per second, with
+	// bursts of Burst traces, or 1 when zero. It cannot be used along with
+	// Rate.
+	MaxPerSecond float64 `yaml:"max_per_second"` // SQREEN_SAMPLING_MAX_PER_SECOND
+	Burst        int     `yaml:"burst"`          // SQREEN_SAMPLING_BURST
+	// Routes are the sampling rates of route templates, the other routes
+	// being sampled according to Rate or MaxPerSecond.
+	Routes map[string]float64 `yaml:"routes"`
+	// AlwaysKeep always samples the traces having point signals or server
+	// errors. SQREEN_SAMPLING_ALWAYS_KEEP.
+	AlwaysKeep bool `yaml:"always_keep"`
+}
+
+// ScrubbingConfig is the scrubbing configuration of the signals.
+type ScrubbingConfig struct {
+	// Enabled enables the scrubbing, which is enabled when nil. Setting it to
+	// false explicitly disables it. SQREEN_SCRUBBING_ENABLED.
+	Enabled *bool `yaml:"enabled"`
+	// Keys is the regular expression of the key names whose values are
+	// redacted. scrub.DefaultKeyRegexp is used when empty.
+	// SQREEN_SCRUBBING_KEYS.
+	Keys string `yaml:"keys"`
+	// Rules are the names of the value rules, among credit_card, jwt,
+	// bearer_token and email. Every rule is used when empty.
+	// SQREEN_SCRUBBING_RULES, comma-separated.
+	Rules []string `yaml:"rules"`
+}
+
+// valueRules are the scrubbing value rules by name.
+var valueRules = map[string]scrub.ValueRule{
+	scrub.CreditCardRule.Name:  scrub.CreditCardRule,
+	scrub.JWTRule.Name:         scrub.JWTRule,
+	scrub.BearerTokenRule.Name: scrub.BearerTokenRule,
+	scrub.EmailRule.Name:       scrub.EmailRule,
+}
+
+// FieldError is the error of an invalid configuration field, named after its
+// file key path or environment variable.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("config: %s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// Load returns the validated configuration read from the file, when the path
+// is not empty, and then from the environment variables, which take
+// precedence. The path defaults to the value of the FileEnv environment
+// variable. The file is YAML, JSON being valid YAML, and durations are strings
+// such as "10s": bare numbers are rejected rather than read as nanoseconds.
+func Load(path string) (*Config, error) {
+	cfg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load returns the configuration read from the file and the environment
+// variables, without validating it.
+func load(path string) (*Config, error) {
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	cfg := &Config{}
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("config: %w", err)
+		}
+		defer f.Close()
+		if err := cfg.decode(f); err != nil {
+			return nil, fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) decode(r io.Reader) error {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Validate returns the errors of the invalid fields, joined by errors.Join().
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, &FieldError{Field: field, Err: fmt.Errorf(format, args...)})
+	}
+
+	if c.Token == "" {
+		invalid("token", "required")
+	}
+	if c.BaseURL != "" {
+		if u, err := url.Parse(c.BaseURL); err != nil {
+			invalid("base_url", "%v", err)
+		} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("base_url", "%q is not an absolute http(s) URL", c.BaseURL)
+		}
+	}
+	for field, d := range map[string]time.Duration{
+		"timeout":            c.Timeout,
+		"retry.delay":        c.Retry.Delay,
+		"batch.flush_period": c.Batch.FlushPeriod,
+	} {
+		if d < 0 {
+			invalid(field, "negative duration %s", d)
+		}
+	}
+	for field, n := range map[string]int{
+		"retry.max_retries":   c.Retry.MaxRetries,
+		"batch.max_size":      c.Batch.MaxSize,
+		"batch.max_queue_len": c.Batch.MaxQueueLen,
+		"sampling.burst":      c.Sampling.Burst,
+	} {
+		if n < 0 {
+			invalid(field, "negative value %d", n)
+		}
+	}
+
+	if r := c.Sampling.Rate; r != nil {
+		if *r < 0 || *r > 1 {
+			invalid("sampling.rate", "%g is not between 0 and 1", *r)
+		}
+		if c.Sampling.MaxPerSecond != 0 {
+			invalid("sampling.max_per_second", "cannot be used along with sampling.rate")
+		}
+	}
+	if c.Sampling.MaxPerSecond < 0 {
+		invalid("sampling.max_per_second", "negative value %g", c.Sampling.MaxPerSecond)
+	}
+	for route, r := range c.Sampling.Routes {
+		if r < 0 || r > 1 {
+			invalid("sampling.routes."+route, "%g is not between 0 and 1", r)
+		}
+	}
+
+	if c.Scrubbing.Keys != "" {
+		if _, err := regexp.Compile(c.Scrubbing.Keys); err != nil {
+			invalid("scrubbing.keys", "%v", err)
+		}
+	}
+	for _, name := range c.Scrubbing.Rules {
+		if _, exists := valueRules[name]; !exists {
+			invalid("scrubbing.rules", "unknown rule %q", name)
+		}
+	}
+
+	// Report the errors in a deterministic order
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].(*FieldError).Field < errs[j].(*FieldError).Field
+	})
+	return errors.Join(errs...)
+}
+
+// envVar is an environment variable setting a configuration field.
+type envVar struct {
+	name string
+	set  func(c *Config, v string) error
+}
+
+var envVars = []envVar{
+	stringEnv("SQREEN_TOKEN", func(c *Config) *string { return &c.Token }),
+	stringEnv("SQREEN_BASE_URL", func(c *Config) *string { return &c.BaseURL }),
+	durationEnv("SQREEN_TIMEOUT", func(c *Config) *time.Duration { return &c.Timeout }),
+	stringEnv("SQREEN_SERVICE", func(c *Config) *string { return &c.Service }),
+	stringEnv("SQREEN_VERSION", func(c *Config) *string { return &c.Version }),
+	stringEnv("SQREEN_ENVIRONMENT", func(c *Config) *string { return &c.Environment }),
+	stringEnv("SQREEN_REGION", func(c *Config) *string { return &c.Region }),
+	intEnv("SQREEN_RETRY_MAX_RETRIES", func(c *Config) *int { return &c.Retry.MaxRetries }),
+	durationEnv("SQREEN_RETRY_DELAY", func(c *Config) *time.Duration { return &c.Retry.Delay }),
+	intEnv("SQREEN_BATCH_MAX_SIZE", func(c *Config) *int { return &c.Batch.MaxSize }),
+	intEnv("SQREEN_BATCH_MAX_QUEUE_LEN", func(c *Config) *int { return &c.Batch.MaxQueueLen }),
+	durationEnv("SQREEN_BATCH_FLUSH_PERIOD", func(c *Config) *time.Duration { return &c.Batch.FlushPeriod }),
+	{"SQREEN_SAMPLING_RATE", func(c *Config, v string) error {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		c.Sampling.Rate = &r
+		return nil
+	}},
+	floatEnv("SQREEN_SAMPLING_MAX_PER_SECOND", func(c *Config) *float64 { return &c.Sampling.MaxPerSecond }),
+	intEnv("SQREEN_SAMPLING_BURST", func(c *Config) *int { return &c.Sampling.Burst }),
+	boolEnv("SQREEN_SAMPLING_ALWAYS_KEEP", func(c *Config) *bool { return &c.Sampling.AlwaysKeep }),
+	{"SQREEN_SCRUBBING_ENABLED", func(c *Config, v string) error {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		c.Scrubbing.Enabled = &enabled
+		return nil
+	}},
+	stringEnv("SQREEN_SCRUBBING_KEYS", func(c *Config) *string { return &c.Scrubbing.Keys }),
+	{"SQREEN_SCRUBBING_RULES", func(c *Config, v string) error {
+		c.Scrubbing.Rules = nil
+		for _, rule := range strings.Split(v, ",") {
+			if rule = strings.TrimSpace(rule); rule != "" {
+				c.Scrubbing.Rules = append(c.Scrubbing.Rules, rule)
+			}
+		}
+		return nil
+	}},
+}
+
+func stringEnv(name string, field func(*Config) *string) envVar {
+	return envVar{name, func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func intEnv(name string, field func(*Config) *int) envVar {
+	return envVar{name, func(c *Config, v string) (err error) {
+		*field(c), err = strconv.Atoi(v)
+		return err
+	}}
+}
+
+func floatEnv(name string, field func(*Config) *float64) envVar {
+	return envVar{name, func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseFloat(v, 64)
+		return err
+	}}
+}
+
+func boolEnv(name string, field func(*Config) *bool) envVar {
+	return envVar{name, func(c *Config, v string) (err error) {
+		*field(c), err = strconv.ParseBool(v)
+		return err
+	}}
+}
+
+func durationEnv(name string, field func(*Config) *time.Duration) envVar {
+	return envVar{name, func(c *Config, v string) (err error) {
+		*field(c), err = time.ParseDuration(v)
+		return err
+	}}
+}
+
+// loadEnv sets the fields of the environment variables that are set.
+func (c *Config) loadEnv() error {
+	var errs []error
+	for _, env := range envVars {
+		v, ok := os.LookupEnv(env.name)
+		if !ok {
+			continue
+		}
+		if err := env.set(c, v); err != nil {
+			errs = append(errs, &FieldError{Field: env.name, Err: err})
+		}
+	}
+	return errors.Join(errs...)
+}
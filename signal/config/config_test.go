@@ -0,0 +1,233 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package config_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("yaml file and environment", func(t *testing.T) {
+		path := writeFile(t, "sqreen.yaml", `
+token: file token
+base_url: https://example.com/api
+timeout: 5s
+service: my service
+retry:
+  max_retries: 3
+  delay: 100ms
+batch:
+  max_size: 10
+  flush_period: 1m
+sampling:
+  rate: 0.5
+  routes:
+    /health: 0
+  always_keep: true
+scrubbing:
+  enabled: true
+  rules: [email, jwt]
+`)
+		t.Setenv("SQREEN_TOKEN", "env token")
+		t.Setenv("SQREEN_BATCH_MAX_QUEUE_LEN", "42")
+		t.Setenv("SQREEN_SCRUBBING_RULES", "credit_card, email")
+
+		cfg, err := config.Load(path)
+		require.NoError(t, err)
+		rate, enabled := 0.5, true
+		require.Equal(t, &config.Config{
+			Token:   "env token",
+			BaseURL: "https://example.com/api",
+			Timeout: 5 * time.Second,
+			Service: "my service",
+			Retry:   config.RetryConfig{MaxRetries: 3, Delay: 100 * time.Millisecond},
+			Batch:   config.BatchConfig{MaxSize: 10, MaxQueueLen: 42, FlushPeriod: time.Minute},
+			Sampling: config.SamplingConfig{
+				Rate:       &rate,
+				Routes:     map[string]float64{"/health": 0},
+				AlwaysKeep: true,
+			},
+			Scrubbing: config.ScrubbingConfig{Enabled: &enabled, Rules: []string{"credit_card", "email"}},
+		}, cfg)
+	})
+
+	t.Run("json file from the environment", func(t *testing.T) {
+		path := writeFile(t, "sqreen.json", "{\n\t\"token\": \"my token\",\n\t\"batch\": {\"flush_period\": \"2s\"}\n}\n")
+		t.Setenv(config.FileEnv, path)
+		cfg, err := config.Load("")
+		require.NoError(t, err)
+		require.Equal(t, "my token", cfg.Token)
+		require.Equal(t, 2*time.Second, cfg.Batch.FlushPeriod)
+	})
+
+	t.Run("environment only", func(t *testing.T) {
+		t.Setenv("SQREEN_TOKEN", "my token")
+		t.Setenv("SQREEN_SAMPLING_MAX_PER_SECOND", "2.5")
+		t.Setenv("SQREEN_SCRUBBING_ENABLED", "false")
+		cfg, err := config.Load("")
+		require.NoError(t, err)
+		require.Equal(t, 2.5, cfg.Sampling.MaxPerSecond)
+		require.NotNil(t, cfg.Scrubbing.Enabled)
+		require.False(t, *cfg.Scrubbing.Enabled)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			name     string
+			file     string
+			env      map[string]string
+			expected string
+		}{
+			{
+				name:     "missing token",
+				expected: "config: token: required",
+			},
+			{
+				name:     "unknown field",
+				file:     "token: my token\nbatch:\n  size: 1\n",
+				expected: "field size not found",
+			},
+			{
+				name:     "invalid duration",
+				file:     "token: my token\ntimeout: 5\n",
+				expected: "cannot unmarshal",
+			},
+			{
+				name:     "float duration",
+				file:     "token: my token\nretry:\n  delay: 0.5\n",
+				expected: "cannot unmarshal",
+			},
+			{
+				name:     "json number duration",
+				file:     `{"token": "my token", "batch": {"flush_period": 1000000000}}`,
+				expected: "cannot unmarshal",
+			},
+			{
+				name:     "invalid environment variable",
+				env:      map[string]string{"SQREEN_TOKEN": "my token", "SQREEN_BATCH_MAX_SIZE": "ten"},
+				expected: `config: SQREEN_BATCH_MAX_SIZE: strconv.Atoi: parsing "ten": invalid syntax`,
+			},
+			{
+				name: "invalid values",
+				file: `
+token: my token
+base_url: example.com
+retry:
+  max_retries: -1
+sampling:
+  rate: 2
+  max_per_second: 10
+scrubbing:
+  keys: "("
+  rules: [phone]
+`,
+				expected: "config: base_url: \"example.com\" is not an absolute http(s) URL\n" +
+					"config: retry.max_retries: negative value -1\n" +
+					"config: sampling.max_per_second: cannot be used along with sampling.rate\n" +
+					"config: sampling.rate: 2 is not between 0 and 1\n" +
+					"config: scrubbing.keys: error parsing regexp: missing closing ): `(`\n" +
+					"config: scrubbing.rules: unknown rule \"phone\"",
+			},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				for k, v := range tc.env {
+					t.Setenv(k, v)
+				}
+				var path string
+				if tc.file != "" {
+					path = writeFile(t, "sqreen.yaml", tc.file)
+				}
+				_, err := config.Load(path)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.expected)
+			})
+		}
+
+		t.Run("field error", func(t *testing.T) {
+			_, err := config.Load("")
+			var fieldErr *config.FieldError
+			require.True(t, errors.As(err, &fieldErr))
+			require.Equal(t, "token", fieldErr.Field)
+		})
+	})
+}
+
+func TestNewSDK(t *testing.T) {
+	received := make(chan []map[string]interface{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/batches", r.URL.Path)
+		require.Equal(t, "my token", r.Header.Get("X-Session-Key"))
+		var batch []map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		received <- batch
+	}))
+	defer srv.Close()
+
+	t.Setenv("SQREEN_TOKEN", "my token")
+	t.Setenv("SQREEN_BASE_URL", srv.URL+"/api")
+	t.Setenv("SQREEN_SERVICE", "my service")
+	t.Setenv("SQREEN_SAMPLING_RATE", "1")
+
+	sdk, err := config.NewSDK("")
+	require.NoError(t, err)
+	require.NotNil(t, sdk.Sampler)
+	require.NotNil(t, sdk.Scrubber)
+	h := sdk.Handler(http.NotFoundHandler())
+	require.NotNil(t, h.Sampler)
+	require.Same(t, sdk.Scrubber, h.Scrubber)
+	require.Same(t, sdk.Scrubber, sdk.Client.Scrubber)
+
+	sdk.Exporter.Export(api.NewPoint("my point", "", time.Now(), nil, nil, nil, nil, nil, nil))
+	require.NoError(t, sdk.Stop(context.Background()))
+	batch := <-received
+	require.Len(t, batch, 1)
+	require.Equal(t, "my service", batch[0]["source"])
+
+	t.Run("defaults", func(t *testing.T) {
+		sdk, err := (&config.Config{Token: "my token"}).Build()
+		require.NoError(t, err)
+		defer sdk.Stop(context.Background())
+		require.Nil(t, sdk.Sampler)
+		// Scrubbing is enabled by default
+		require.NotNil(t, sdk.Scrubber)
+		require.Same(t, sdk.Scrubber, sdk.Client.Scrubber)
+		require.Nil(t, sdk.Client.Resource)
+		require.Equal(t, "https://ingestion.sqreen.com/", sdk.Client.BaseURL.String())
+	})
+
+	t.Run("scrubbing disabled", func(t *testing.T) {
+		enabled := false
+		sdk, err := (&config.Config{Token: "my token", Scrubbing: config.ScrubbingConfig{Enabled: &enabled}}).Build()
+		require.NoError(t, err)
+		defer sdk.Stop(context.Background())
+		require.Nil(t, sdk.Scrubber)
+		require.Nil(t, sdk.Client.Scrubber)
+	})
+
+	t.Run("invalid configuration", func(t *testing.T) {
+		t.Setenv("SQREEN_SAMPLING_RATE", "2")
+		_, err := config.NewSDK("")
+		require.EqualError(t, err, "config: sampling.rate: 2 is not between 0 and 1")
+	})
+}
@@ -0,0 +1,20 @@
+module github.com/sqreen/go-sdk/signal/config
+
+go 1.23
+
+require (
+	github.com/sqreen/go-sdk/signal v0.1.0
+	github.com/stretchr/testify v1.6.1
+	go.yaml.in/yaml/v3 v3.0.5
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
+)
+
+// The replacement builds the module against the signal module of the
+// repository. It is ignored by the users of the module, who get the released
+// version required above.
+replace github.com/sqreen/go-sdk/signal => ../
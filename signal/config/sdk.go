@@ -0,0 +1,141 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/scrub"
+)
+
+// SDK is the set of SDK components built from a configuration.
+type SDK struct {
+	Client   *client.Client
+	Exporter *client.BatchExporter
+	// Sampler is the sampler of the HTTP traces, nil when every trace is
+	// sampled.
+	Sampler sqhttp.Sampler
+	// Scrubber is the scrubber of the signals sent by the client, also used
+	// by the HTTP handlers to scrub their traces (cf. http.ScrubTrace()), nil
+	// when scrubbing is explicitly disabled.
+	Scrubber *scrub.Scrubber
+}
+
+// NewSDK returns the SDK components built from the configuration loaded by
+// Load(path).
+func NewSDK(path string) (*SDK, error) {
+	cfg, err := load(path)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Build()
+}
+
+// Build validates the configuration and returns the SDK components it
+// configures. The exporter is started and must be stopped (cf. SDK.Stop()).
+func (c *Config) Build() (*SDK, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	cl := client.NewClient(&http.Client{Timeout: timeout}, c.Token)
+	if c.BaseURL != "" {
+		baseURL := c.BaseURL
+		// Endpoints are resolved relative to the base URL path
+		if !strings.HasSuffix(baseURL, "/") {
+			baseURL += "/"
+		}
+		cl.BaseURL, _ = url.Parse(baseURL)
+	}
+	if c.Service != "" || c.Version != "" || c.Environment != "" || c.Region != "" {
+		cl.Resource = &api.Resource{
+			Service:     c.Service,
+			Version:     c.Version,
+			Environment: c.Environment,
+			Region:      c.Region,
+		}
+	}
+
+	scrubber := c.Scrubbing.scrubber()
+	cl.Scrubber = scrubber
+
+	return &SDK{
+		Client: cl,
+		Exporter: client.NewBatchExporter(cl, client.BatchConfig{
+			MaxBatchSize: c.Batch.MaxSize,
+			MaxQueueLen:  c.Batch.MaxQueueLen,
+			FlushPeriod:  c.Batch.FlushPeriod,
+			MaxRetries:   c.Retry.MaxRetries,
+			RetryDelay:   c.Retry.Delay,
+		}),
+		Sampler:  c.Sampling.sampler(),
+		Scrubber: scrubber,
+	}, nil
+}
+
+func (c *SamplingConfig) sampler() sqhttp.Sampler {
+	var sampler sqhttp.Sampler
+	switch {
+	case c.Rate != nil:
+		sampler = sqhttp.NewFixedRateSampler(*c.Rate)
+	case c.MaxPerSecond > 0:
+		burst := c.Burst
+		if burst == 0 {
+			burst = 1
+		}
+		sampler = sqhttp.NewTokenBucketSampler(c.MaxPerSecond, burst)
+	}
+	if len(c.Routes) > 0 {
+		sampler = sqhttp.NewRouteRateSampler(c.Routes, sampler)
+	}
+	if sampler != nil && c.AlwaysKeep {
+		sampler = sqhttp.NewAlwaysKeepSampler(sampler)
+	}
+	return sampler
+}
+
+func (c *ScrubbingConfig) scrubber() *scrub.Scrubber {
+	if c.Enabled != nil && !*c.Enabled {
+		return nil
+	}
+	keys := scrub.DefaultKeyRegexp
+	if c.Keys != "" {
+		keys = regexp.MustCompile(c.Keys)
+	}
+	rules := scrub.DefaultValueRules
+	if len(c.Rules) > 0 {
+		rules = make([]scrub.ValueRule, 0, len(c.Rules))
+		for _, name := range c.Rules {
+			rules = append(rules, valueRules[name])
+		}
+	}
+	return scrub.NewScrubber(keys, rules)
+}
+
+// Handler returns a new HTTP middleware handler serving the requests with next
+// and exporting their traces with the SDK exporter, sampler and scrubber.
+func (s *SDK) Handler(next http.Handler) *sqhttp.Handler {
+	h := sqhttp.NewHandler(next, s.Exporter)
+	h.Sampler = s.Sampler
+	h.Scrubber = s.Scrubber
+	return h
+}
+
+// Stop stops the exporter, sending its remaining signals (cf.
+// client.BatchExporter.Stop()).
+func (s *SDK) Stop(ctx context.Context) error {
+	return s.Exporter.Stop(ctx)
+}
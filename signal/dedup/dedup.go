@@ -0,0 +1,276 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package dedup provides an exporter stage collapsing identical point signals
+// emitted within a time window, such as the thousands of identical points an
+// attack can trigger per second, into a single point having the occurrence
+// count and the first and last occurrence times. Identical points of a trace,
+// such as the ones added by trace.AddPoint(), are collapsed within the trace.
+package dedup
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+)
+
+// DefaultMaxEntries is the maximum number of distinct points being collapsed
+// at the same time when the exporter configuration doesn't specify any.
+const DefaultMaxEntries = 10000
+
+// Key returns an identity component of a point. Points whose identity
+// components are all equal are identical.
+type Key func(p *api.Point) string
+
+// Identity keys.
+var (
+	// NameKey is the name of the point.
+	NameKey Key = func(p *api.Point) string { return p.Name }
+	// SourceKey is the source of the point.
+	SourceKey Key = func(p *api.Point) string { return p.Source }
+	// ActorIPKey is the list of IP addresses of the point actor, when it is a
+	// HTTP actor.
+	ActorIPKey Key = func(p *api.Point) string {
+		if a, ok := p.Actor.(*sqhttp.Actor); ok && a != nil {
+			return strings.Join(a.IPAddresses, ",")
+		}
+		return ""
+	}
+
+	// DefaultKeys is the list of identity keys used when none is provided.
+	DefaultKeys = []Key{NameKey, SourceKey, ActorIPKey}
+)
+
+// Config is the configuration of the deduplication stage. Default values are
+// used for zero fields.
+type Config struct {
+	// Window is the duration during which identical points are collapsed,
+	// starting from the first occurrence.
+	Window time.Duration
+	// Keys is the list of identity keys of points. DefaultKeys is used when
+	// empty.
+	Keys []Key
+	// MaxEntries is the maximum number of distinct points being collapsed at
+	// the same time. Points of new identities are exported as-is once it is
+	// reached.
+	MaxEntries int
+}
+
+// DefaultWindow is the deduplication window used when the configuration
+// doesn't specify any.
+const DefaultWindow = 10 * time.Second
+
+// Exporter is the deduplication stage in front of another exporter. Points
+// are held until the end of their window, while other signals are directly
+// exported, traces having their identical data points collapsed. Run() must be
+// running in order to export the points at the end of their window.
+type Exporter struct {
+	next    client.Exporter
+	cfg     Config
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	point *api.Point
+	occ   api.Occurrences
+}
+
+// NewExporter returns a new deduplication stage exporting to next.
+func NewExporter(next client.Exporter, cfg Config) *Exporter {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if len(cfg.Keys) == 0 {
+		cfg.Keys = DefaultKeys
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMaxEntries
+	}
+	return &Exporter{
+		next:    next,
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+	}
+}
+
+func (e *Exporter) Export(s api.SignalFace) {
+	if t := api.TraceOf(s); t != nil {
+		e.next.Export(e.dedupTrace(s, t))
+		return
+	}
+	p := asPoint(s)
+	if p == nil {
+		e.next.Export(s)
+		return
+	}
+	now := time.Now()
+	if expired := e.add(p, now); expired != nil {
+		e.export(expired)
+	}
+}
+
+// add adds the point occurrence. It returns the entry of the previous window
+// of the point, if expired.
+func (e *Exporter) add(p *api.Point, now time.Time) (expired *entry) {
+	key := e.key(p)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if ent, exists := e.entries[key]; exists {
+		if now.Sub(ent.occ.FirstSeen) < e.cfg.Window {
+			ent.occ.Count++
+			ent.occ.LastSeen = now
+			return nil
+		}
+		expired = ent
+	} else if len(e.entries) >= e.cfg.MaxEntries {
+		return &entry{point: p, occ: api.Occurrences{Count: 1}}
+	}
+
+	e.entries[key] = &entry{
+		point: p,
+		occ:   api.Occurrences{Count: 1, FirstSeen: now, LastSeen: now},
+	}
+	return expired
+}
+
+func (e *Exporter) key(p *api.Point) string {
+	var b strings.Builder
+	for _, k := range e.cfg.Keys {
+		// Prefix the components with their length in order to avoid collisions
+		// between components
+		c := k(p)
+		b.WriteString(strconv.Itoa(len(c)))
+		b.WriteByte(':')
+		b.WriteString(c)
+	}
+	return b.String()
+}
+
+// dedupTrace returns a copy of the trace s, of the same type, whose identical
+// data points are collapsed into the first of them. The trace itself is
+// returned when it has no identical points.
+func (e *Exporter) dedupTrace(s api.SignalFace, t *api.Trace) api.SignalFace {
+	var (
+		data     []*api.Signal
+		index    map[string]int
+		collapse bool
+	)
+	for _, sig := range t.Data {
+		p := asPoint(sig)
+		if p == nil {
+			data = append(data, sig)
+			continue
+		}
+		key := e.key(p)
+		if i, exists := index[key]; exists {
+			collapsed := *data[i]
+			collapsed.Occurrences = mergeOccurrences(occurrences(data[i]), occurrences(sig))
+			data[i] = &collapsed
+			collapse = true
+			continue
+		}
+		if index == nil {
+			index = make(map[string]int)
+		}
+		index[key] = len(data)
+		data = append(data, sig)
+	}
+	if !collapse {
+		return s
+	}
+
+	cp := reflect.New(reflect.TypeOf(s).Elem())
+	cp.Elem().Set(reflect.ValueOf(s).Elem())
+	deduped := cp.Interface().(api.SignalFace)
+	api.TraceOf(deduped).Data = data
+	return deduped
+}
+
+// occurrences returns the occurrences of the signal, a single one at its time
+// unless it already collapses several ones.
+func occurrences(s *api.Signal) api.Occurrences {
+	if s.Occurrences != nil {
+		return *s.Occurrences
+	}
+	return api.Occurrences{Count: 1, FirstSeen: s.Time, LastSeen: s.Time}
+}
+
+func mergeOccurrences(a, b api.Occurrences) *api.Occurrences {
+	merged := a
+	merged.Count += b.Count
+	if b.FirstSeen.Before(merged.FirstSeen) {
+		merged.FirstSeen = b.FirstSeen
+	}
+	if b.LastSeen.After(merged.LastSeen) {
+		merged.LastSeen = b.LastSeen
+	}
+	return &merged
+}
+
+// Run exports the points whose window ended every window until the context is
+// canceled. Every held point is exported before returning.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.Flush(time.Time{})
+			return
+		case now := <-ticker.C:
+			e.Flush(now)
+		}
+	}
+}
+
+// Flush exports the points whose window ended before now, or every held point
+// when now is the zero time.
+func (e *Exporter) Flush(now time.Time) {
+	var expired []*entry
+	e.mu.Lock()
+	for key, ent := range e.entries {
+		if now.IsZero() || now.Sub(ent.occ.FirstSeen) >= e.cfg.Window {
+			expired = append(expired, ent)
+			delete(e.entries, key)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, ent := range expired {
+		e.export(ent)
+	}
+}
+
+func (e *Exporter) export(ent *entry) {
+	if ent.occ.Count <= 1 {
+		e.next.Export(ent.point)
+		return
+	}
+	p := *ent.point
+	occ := ent.occ
+	p.Occurrences = &occ
+	e.next.Export(&p)
+}
+
+func asPoint(s api.SignalFace) *api.Point {
+	switch s := s.(type) {
+	case *api.Point:
+		return s
+	case *api.Signal:
+		if s != nil && s.Type == "point" {
+			return (*api.Point)(s)
+		}
+	}
+	return nil
+}
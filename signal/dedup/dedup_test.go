@@ -0,0 +1,141 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package dedup_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/dedup"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporter(t *testing.T) {
+	newPoint := func(name, ip string) *api.Point {
+		actor := sqhttp.NewActor([]string{ip}, "", nil)
+		return api.NewPoint(name, "test", time.Now(), actor, nil, nil, nil, nil, nil)
+	}
+
+	t.Run("collapse identical points", func(t *testing.T) {
+		var next testutil.Exporter
+		e := dedup.NewExporter(&next, dedup.Config{Window: time.Hour})
+
+		for i := 0; i < 1000; i++ {
+			e.Export(newPoint("attack", "1.2.3.4"))
+		}
+		e.Export(newPoint("attack", "5.6.7.8"))
+		e.Export(newPoint("other", "1.2.3.4"))
+		metric := api.NewSumMetric("metric", "test", time.Now(), time.Now(), time.Minute, nil)
+		e.Export(metric)
+
+		// Only non-points are directly exported
+		require.Equal(t, []api.SignalFace{metric}, next.Signals())
+
+		e.Flush(time.Now())
+		require.Len(t, next.Signals(), 1)
+
+		e.Flush(time.Time{})
+		exported := next.Signals()
+		require.Len(t, exported, 4)
+
+		counts := map[string]int64{}
+		for _, s := range exported[1:] {
+			p := s.(*api.Point)
+			key := p.Name + " " + p.Actor.(*sqhttp.Actor).IPAddresses[0]
+			if p.Occurrences == nil {
+				counts[key] = 1
+				continue
+			}
+			require.False(t, p.Occurrences.LastSeen.Before(p.Occurrences.FirstSeen))
+			counts[key] = p.Occurrences.Count
+		}
+		require.Equal(t, map[string]int64{"attack 1.2.3.4": 1000, "attack 5.6.7.8": 1, "other 1.2.3.4": 1}, counts)
+	})
+
+	t.Run("custom identity keys", func(t *testing.T) {
+		var next testutil.Exporter
+		e := dedup.NewExporter(&next, dedup.Config{Window: time.Hour, Keys: []dedup.Key{dedup.NameKey}})
+		e.Export(newPoint("attack", "1.2.3.4"))
+		e.Export(newPoint("attack", "5.6.7.8"))
+		e.Flush(time.Time{})
+		exported := next.Signals()
+		require.Len(t, exported, 1)
+		require.Equal(t, int64(2), exported[0].(*api.Point).Occurrences.Count)
+
+		t.Run("no collisions between components", func(t *testing.T) {
+			var next testutil.Exporter
+			e := dedup.NewExporter(&next, dedup.Config{Window: time.Hour, Keys: []dedup.Key{dedup.NameKey, dedup.SourceKey}})
+			e.Export(api.NewPoint(`\`, "|", time.Now(), nil, nil, nil, nil, nil, nil))
+			e.Export(api.NewPoint(`|\`, "", time.Now(), nil, nil, nil, nil, nil, nil))
+			e.Flush(time.Time{})
+			require.Len(t, next.Signals(), 2)
+		})
+	})
+
+	t.Run("trace points", func(t *testing.T) {
+		var next testutil.Exporter
+		e := dedup.NewExporter(&next, dedup.Config{Window: time.Hour})
+		start := time.Now()
+		attack := func(d time.Duration) *api.Signal {
+			return (*api.Signal)(api.NewPoint("attack", "test", start.Add(d), nil, nil, nil, nil, nil, nil))
+		}
+		other := (*api.Signal)(api.NewPoint("other", "test", start, nil, nil, nil, nil, nil, nil))
+		data := []*api.Signal{attack(0), other, attack(2 * time.Second), attack(time.Second)}
+		tr := sqhttp.NewTrace("test", start, nil, nil, nil, data)
+		e.Export(tr)
+
+		// Traces are directly exported with their identical points collapsed
+		exported := next.Signals()
+		require.Len(t, exported, 1)
+		deduped, ok := exported[0].(*sqhttp.Trace)
+		require.True(t, ok)
+		require.Len(t, deduped.Data, 2)
+		require.Equal(t, &api.Occurrences{Count: 3, FirstSeen: start, LastSeen: start.Add(2 * time.Second)}, deduped.Data[0].Occurrences)
+		require.Same(t, other, deduped.Data[1])
+
+		// The trace is left untouched
+		require.Len(t, tr.Data, 4)
+		require.Nil(t, data[0].Occurrences)
+
+		t.Run("without identical points", func(t *testing.T) {
+			tr := sqhttp.NewTrace("test", start, nil, nil, nil, []*api.Signal{attack(0), other})
+			e.Export(tr)
+			require.Same(t, tr, next.Signals()[1])
+		})
+	})
+
+	t.Run("window", func(t *testing.T) {
+		var next testutil.Exporter
+		e := dedup.NewExporter(&next, dedup.Config{Window: time.Millisecond})
+		e.Export(newPoint("attack", "1.2.3.4"))
+		time.Sleep(2 * time.Millisecond)
+		// The new window exports the expired one
+		e.Export(newPoint("attack", "1.2.3.4"))
+		require.Len(t, next.Signals(), 1)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			e.Run(ctx)
+			close(done)
+		}()
+		require.Eventually(t, func() bool { return len(next.Signals()) == 2 }, time.Second, time.Millisecond)
+		cancel()
+		<-done
+	})
+
+	t.Run("max entries", func(t *testing.T) {
+		var next testutil.Exporter
+		e := dedup.NewExporter(&next, dedup.Config{Window: time.Hour, MaxEntries: 1})
+		e.Export(newPoint("attack", "1.2.3.4"))
+		e.Export(newPoint("other", "1.2.3.4"))
+		e.Export(newPoint("other", "1.2.3.4"))
+		require.Len(t, next.Signals(), 2)
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package exception reports application errors and panics as exception points
+// attached to the request trace (cf. package trace).
+package exception
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+const (
+	// PointName is the name of exception points.
+	PointName = "exception"
+	// PayloadSchema is the payload schema of exception points.
+	PayloadSchema = "exception/2020-01-01T00:00:00.000Z"
+)
+
+// Payload is the payload of exception points.
+type Payload struct {
+	// Type is the Go type of the error, such as "*fs.PathError".
+	Type string `json:"type"`
+	// Message is the error message.
+	Message string `json:"message"`
+	// Causes is the chain of errors wrapped by the error, obtained using
+	// errors.Unwrap().
+	Causes []Cause `json:"causes,omitempty"`
+	// Recovered is true when the exception is a recovered panic.
+	Recovered bool `json:"recovered,omitempty"`
+}
+
+// Cause is an error wrapped by the exception error.
+type Cause struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// CaptureError adds the exception point of the error to the trace collector
+// of the context, without source so that it takes the one of the trace (cf.
+// CaptureErrorFrom()).
+func CaptureError(ctx context.Context, err error) bool {
+	return captureError(ctx, "", err)
+}
+
+// CaptureErrorFrom adds the exception point of the error having the given
+// source to the trace collector of the context. Its location is captured
+// according to the location capture configuration (cf.
+// api.EnableLocationCapture()). It returns false when the context has no
+// collector or when the point was dropped.
+func CaptureErrorFrom(ctx context.Context, source string, err error) bool {
+	return captureError(ctx, source, err)
+}
+
+func captureError(ctx context.Context, source string, err error) bool {
+	if err == nil || trace.FromContext(ctx) == nil {
+		return false
+	}
+	return trace.AddPoint(ctx, NewPoint(source, err, false, api.CaptureLocation(2, api.LocationCaptureConfig())))
+}
+
+// NewPoint returns the exception point of the error.
+func NewPoint(source string, err error, recovered bool, location *api.Location) *api.Point {
+	payload := &Payload{
+		Type:      fmt.Sprintf("%T", err),
+		Message:   err.Error(),
+		Recovered: recovered,
+	}
+	for cause := errors.Unwrap(err); cause != nil; cause = errors.Unwrap(cause) {
+		payload.Causes = append(payload.Causes, Cause{Type: fmt.Sprintf("%T", cause), Message: cause.Error()})
+	}
+	return api.NewPoint(PointName, source, time.Now(), nil, nil, nil, location, nil, api.NewPayload(PayloadSchema, payload))
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package exception_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/exception"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureError(t *testing.T) {
+	t.Run("without trace", func(t *testing.T) {
+		require.False(t, exception.CaptureError(context.Background(), errors.New("oops")))
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		ctx := trace.NewContext(context.Background(), trace.NewCollector(0, nil))
+		require.False(t, exception.CaptureError(ctx, nil))
+	})
+
+	t.Run("wrapped error", func(t *testing.T) {
+		c := trace.NewCollector(0, nil)
+		ctx := trace.NewContext(context.Background(), c)
+		_, err := os.Open("/does/not/exist")
+		err = fmt.Errorf("loading config: %w", err)
+		require.True(t, exception.CaptureError(ctx, err))
+
+		signals := c.Signals()
+		require.Len(t, signals, 1)
+		require.Equal(t, exception.PointName, signals[0].Name)
+		require.Equal(t, exception.PayloadSchema, signals[0].SignalPayload.Schema)
+
+		p := signals[0].SignalPayload.Payload.(*exception.Payload)
+		require.Equal(t, "*fmt.wrapError", p.Type)
+		require.Equal(t, err.Error(), p.Message)
+		require.False(t, p.Recovered)
+		require.Len(t, p.Causes, 2)
+		require.Equal(t, "*fs.PathError", p.Causes[0].Type)
+		require.Equal(t, "syscall.Errno", p.Causes[1].Type)
+
+		requireStackStartsWith(t, signals[0], "exception_test.TestCaptureError")
+	})
+
+	t.Run("source and location config", func(t *testing.T) {
+		api.EnableLocationCapture(&api.LocationConfig{MaxFrames: 1})
+		defer api.EnableLocationCapture(nil)
+		c := trace.NewCollector(0, nil)
+		ctx := trace.NewContext(context.Background(), c)
+		require.True(t, exception.CaptureErrorFrom(ctx, "test", errors.New("oops")))
+
+		signals := c.Signals()
+		require.Len(t, signals, 1)
+		require.Equal(t, "test", signals[0].Source)
+		requireStackStartsWith(t, signals[0], "exception_test.TestCaptureError")
+		require.Len(t, signals[0].Location.Stack, 1)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		typ     string
+		message string
+	}{
+		{
+			name:    "panic value",
+			handler: func(http.ResponseWriter, *http.Request) { panic("oops") },
+			typ:     "*exception.PanicError",
+			message: "panic: oops",
+		},
+		{
+			name: "runtime error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				var m map[string]int
+				m[r.URL.Path]++
+			},
+			typ:     "runtime.plainError",
+			message: "assignment to entry in nil map",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c := trace.NewCollector(0, nil)
+			h := exception.NewHandler(tc.handler)
+			h.Source = "test"
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			h.ServeHTTP(rec, req.WithContext(trace.NewContext(req.Context(), c)))
+			require.Equal(t, http.StatusInternalServerError, rec.Code)
+
+			signals := c.Signals()
+			require.Len(t, signals, 1)
+			require.Equal(t, "test", signals[0].Source)
+			p := signals[0].SignalPayload.Payload.(*exception.Payload)
+			require.Equal(t, tc.typ, p.Type)
+			require.Equal(t, tc.message, p.Message)
+			require.True(t, p.Recovered)
+			requireStackStartsWith(t, signals[0], "exception_test.TestHandler.func")
+		})
+	}
+
+	t.Run("repanic", func(t *testing.T) {
+		c := trace.NewCollector(0, nil)
+		h := exception.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("oops") }))
+		h.Repanic = true
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		require.PanicsWithValue(t, "oops", func() {
+			h.ServeHTTP(httptest.NewRecorder(), req.WithContext(trace.NewContext(req.Context(), c)))
+		})
+		require.Len(t, c.Signals(), 1)
+	})
+
+	t.Run("response already written", func(t *testing.T) {
+		c := trace.NewCollector(0, nil)
+		h := exception.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+			panic("oops")
+		}))
+		w := &headerRecorder{ResponseRecorder: httptest.NewRecorder()}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		h.ServeHTTP(w, req.WithContext(trace.NewContext(req.Context(), c)))
+		require.Equal(t, []int{http.StatusAccepted}, w.statuses)
+		require.Len(t, c.Signals(), 1)
+	})
+
+	t.Run("abort handler", func(t *testing.T) {
+		c := trace.NewCollector(0, nil)
+		h := exception.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic(http.ErrAbortHandler) }))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		require.Panics(t, func() {
+			h.ServeHTTP(httptest.NewRecorder(), req.WithContext(trace.NewContext(req.Context(), c)))
+		})
+		require.Empty(t, c.Signals())
+	})
+}
+
+// headerRecorder records the status codes written to the response.
+type headerRecorder struct {
+	*httptest.ResponseRecorder
+	statuses []int
+}
+
+func (r *headerRecorder) WriteHeader(status int) {
+	r.statuses = append(r.statuses, status)
+	r.ResponseRecorder.WriteHeader(status)
+}
+
+func requireStackStartsWith(t *testing.T, s *api.Signal, function string) {
+	loc := s.Location
+	require.NotNil(t, loc)
+	require.NotEmpty(t, loc.Stack)
+	require.True(t, strings.Contains(loc.Stack[0].Function, function), loc.Stack[0].Function)
+	require.NotEmpty(t, loc.Stack[0].File)
+	require.NotZero(t, loc.Stack[0].Line)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package exception
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/internal/responsewriter"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+// Handler is a net/http middleware recovering the panics of the next handler
+// and adding them as exception points to the request trace. It must be
+// wrapped by the middleware creating the trace collector, such as
+// sqhttp.Handler, so that the points are exported with the trace.
+type Handler struct {
+	// Source is the signal source of the points.
+	Source string
+	// Repanic re-panics with the recovered value once the exception point was
+	// added instead of responding with a 500 status code, which is only done
+	// when the response header wasn't written yet.
+	Repanic bool
+
+	next http.Handler
+}
+
+// NewHandler returns a new recovery middleware serving the requests with next.
+func NewHandler(next http.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+// PanicError is the error of recovered panic values not being errors.
+type PanicError struct {
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := responsewriter.New(w)
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			panic(v)
+		}
+		err, ok := v.(error)
+		if !ok {
+			err = &PanicError{Value: v}
+		}
+		trace.AddPoint(r.Context(), NewPoint(h.Source, err, true, api.CaptureLocation(0, api.LocationCaptureConfig())))
+		if h.Repanic {
+			panic(v)
+		}
+		if !rw.Committed() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}()
+	h.next.ServeHTTP(rw.Wrap(), r)
+}
@@ -1,9 +1,13 @@
 module github.com/sqreen/go-sdk/signal
 
-go 1.12
+// Go 1.23 is required by the route templates of the HTTP middleware, which
+// rely on http.Request.Pattern, along with log/slog, net/netip and errors.Join.
+go 1.23
+
+require github.com/stretchr/testify v1.6.1
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/stretchr/testify v1.6.1
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
 )
@@ -0,0 +1,25 @@
+module github.com/sqreen/go-sdk/signal/grpc
+
+go 1.23.0
+
+require (
+	github.com/sqreen/go-sdk/signal v0.1.0
+	github.com/stretchr/testify v1.6.1
+	google.golang.org/grpc v1.75.1
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.41.0 // indirect
+	golang.org/x/sys v0.33.0 // indirect
+	golang.org/x/text v0.26.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250707201910-8d1bb00bc6a7 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200615113413-eeeca48fe776 // indirect
+)
+
+// The replacement builds the module against the signal module of the
+// repository. It is ignored by the users of the module, who get the released
+// version required above.
+replace github.com/sqreen/go-sdk/signal => ../
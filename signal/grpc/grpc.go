@@ -0,0 +1,48 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package grpc provides security signals describing gRPC traces, along with the
+// gRPC server interceptors building them. A gRPC trace describes a gRPC call,
+// having a set of security signals such as events, attacks, errors, etc.
+package grpc
+
+import (
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+)
+
+type Trace api.Trace
+
+// Context is the gRPC context of a trace.
+type Context struct {
+	Start time.Time `json:"start_processing_time"`
+	End   time.Time `json:"end_processing_time"`
+	// FullMethod is the full method name of the call, eg.
+	// "/package.Service/Method".
+	FullMethod string `json:"full_method"`
+	// PeerAddress is the address of the client.
+	PeerAddress string `json:"peer_address"`
+	// Metadata is the list of request metadata key-value pairs.
+	Metadata [][]string `json:"metadata"`
+	// StatusCode is the name of the gRPC status code of the call, eg. "OK".
+	StatusCode string `json:"status_code"`
+	// RequestSize and ResponseSize are the total sizes in bytes of the
+	// received and sent messages.
+	RequestSize  int64 `json:"request_size"`
+	ResponseSize int64 `json:"response_size"`
+	// RequestMessages and ResponseMessages are the numbers of received and
+	// sent messages.
+	RequestMessages  int `json:"request_messages"`
+	ResponseMessages int `json:"response_messages"`
+}
+
+func NewTrace(source string, t time.Time, a *sqhttp.Actor, infra interface{}, c *Context, d []*api.Signal) *Trace {
+	return (*Trace)(api.NewTrace("", source, t, a, nil, infra, nil, newContext(c), nil, d))
+}
+
+func newContext(context *Context) *api.SignalContext {
+	return api.NewContext("grpc/2020-01-01T00:00:00.000Z", context)
+}
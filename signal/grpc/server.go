@@ -0,0 +1,161 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package grpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Interceptor provides the gRPC server interceptors building the gRPC trace of
+// every call they intercept. The call context given to the handler stores the
+// trace collector so that signals can be attached to the trace (cf. package
+// trace).
+type Interceptor struct {
+	// Source is the signal source of the traces.
+	Source string
+	// MaxSignals is the maximum number of signals per trace.
+	// trace.DefaultMaxSignals is used when zero.
+	MaxSignals int
+	// Budget limits the total number of signals and bytes buffered by the
+	// in-flight calls. The default budget of the trace package is used when
+	// nil (cf. trace.NewCollector()).
+	Budget *trace.Budget
+	// Metadata is the policy of the capture of the request metadata.
+	// sqhttp.DefaultHeaderPolicy is used when nil.
+	Metadata *sqhttp.HeaderPolicy
+
+	exporter client.Exporter
+}
+
+// NewInterceptor returns a new interceptor exporting the call traces with the
+// given exporter.
+func NewInterceptor(exporter client.Exporter) *Interceptor {
+	return &Interceptor{exporter: exporter}
+}
+
+// Unary returns the unary server interceptor.
+func (i *Interceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		c := trace.NewCollector(i.MaxSignals, i.Budget)
+		defer c.Release()
+
+		resp, err := handler(trace.NewContext(ctx, c), req)
+
+		callCtx := i.newContext(ctx, info.FullMethod, start, time.Now(), err)
+		callCtx.RequestMessages, callCtx.RequestSize = 1, messageSize(req)
+		if err == nil {
+			callCtx.ResponseMessages, callCtx.ResponseSize = 1, messageSize(resp)
+		}
+		i.export(ctx, c, callCtx)
+		return resp, err
+	}
+}
+
+// Stream returns the stream server interceptor.
+func (i *Interceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := ss.Context()
+		c := trace.NewCollector(i.MaxSignals, i.Budget)
+		defer c.Release()
+
+		stream := &serverStream{ServerStream: ss, ctx: trace.NewContext(ctx, c)}
+		err := handler(srv, stream)
+
+		callCtx := i.newContext(ctx, info.FullMethod, start, time.Now(), err)
+		callCtx.RequestMessages, callCtx.RequestSize = stream.received, stream.receivedSize
+		callCtx.ResponseMessages, callCtx.ResponseSize = stream.sent, stream.sentSize
+		i.export(ctx, c, callCtx)
+		return err
+	}
+}
+
+func (i *Interceptor) newContext(ctx context.Context, fullMethod string, start, end time.Time, err error) *Context {
+	var peerAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		peerAddr = p.Addr.String()
+	}
+
+	policy := i.Metadata
+	if policy == nil {
+		policy = sqhttp.DefaultHeaderPolicy
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return &Context{
+		Start:       start,
+		End:         end,
+		FullMethod:  fullMethod,
+		PeerAddress: peerAddr,
+		Metadata:    policy.Capture(http.Header(md)),
+		StatusCode:  status.Code(err).String(),
+	}
+}
+
+func (i *Interceptor) export(ctx context.Context, c *trace.Collector, callCtx *Context) {
+	var ips []string
+	if host, _, err := net.SplitHostPort(callCtx.PeerAddress); err == nil {
+		ips = []string{host}
+	}
+	var userAgent string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			userAgent = ua[0]
+		}
+	}
+	actor := sqhttp.NewActor(ips, userAgent, c.Identifiers())
+	i.exporter.Export(NewTrace(i.Source, callCtx.Start, actor, nil, callCtx, c.Signals()))
+}
+
+// serverStream wraps the server stream of the handler in order to provide the
+// context storing the trace collector and to count the messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx                    context.Context
+	received, sent         int
+	receivedSize, sentSize int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received++
+		s.receivedSize += messageSize(m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent++
+		s.sentSize += messageSize(m)
+	}
+	return err
+}
+
+func messageSize(m interface{}) int64 {
+	if msg, ok := m.(proto.Message); ok {
+		return int64(proto.Size(msg))
+	}
+	return 0
+}
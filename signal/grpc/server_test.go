@@ -0,0 +1,132 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package grpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqgrpc "github.com/sqreen/go-sdk/signal/grpc"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestInterceptor(t *testing.T) {
+	var exporter testutil.Exporter
+	i := sqgrpc.NewInterceptor(&exporter)
+	i.Source = "test"
+	i.Metadata = &sqhttp.HeaderPolicy{Allow: []string{"x-custom"}}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(i.Unary()), grpc.StreamInterceptor(i.Stream()))
+	healthpb.RegisterHealthServer(srv, &healthServer{})
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUserAgent("my ua"))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-custom", "value", "x-other", "other")
+
+	t.Run("unary", func(t *testing.T) {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "my service"})
+		require.NoError(t, err)
+		require.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+		tr := lastTrace(t, &exporter)
+		require.Equal(t, "test", tr.Source)
+		require.Len(t, tr.Data, 1)
+		require.Equal(t, "checked", tr.Data[0].Name)
+		require.Contains(t, tr.Actor.(*sqhttp.Actor).UserAgent, "my ua")
+		require.Equal(t, map[string]string{"uid": "42"}, tr.Actor.(*sqhttp.Actor).Identifiers)
+
+		c := tr.Context.(*sqgrpc.Context)
+		require.Equal(t, "/grpc.health.v1.Health/Check", c.FullMethod)
+		require.Equal(t, "OK", c.StatusCode)
+		require.Equal(t, [][]string{{"x-custom", "value"}}, c.Metadata)
+		require.Equal(t, 1, c.RequestMessages)
+		require.Equal(t, int64(len("my service")+2), c.RequestSize)
+		require.Equal(t, 1, c.ResponseMessages)
+		require.Equal(t, int64(2), c.ResponseSize)
+		require.False(t, c.End.Before(c.Start))
+	})
+
+	t.Run("unary error", func(t *testing.T) {
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "unknown"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		c := lastTrace(t, &exporter).Context.(*sqgrpc.Context)
+		require.Equal(t, "NotFound", c.StatusCode)
+		require.Equal(t, 0, c.ResponseMessages)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "my service"})
+		require.NoError(t, err)
+		for n := 0; n < 2; n++ {
+			_, err := stream.Recv()
+			require.NoError(t, err)
+		}
+
+		tr := lastTrace(t, &exporter)
+		require.Len(t, tr.Data, 1)
+		require.Equal(t, "watched", tr.Data[0].Name)
+		c := tr.Context.(*sqgrpc.Context)
+		require.Equal(t, "/grpc.health.v1.Health/Watch", c.FullMethod)
+		require.Equal(t, "OK", c.StatusCode)
+		require.Equal(t, 1, c.RequestMessages)
+		require.Equal(t, 2, c.ResponseMessages)
+	})
+}
+
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if req.Service == "unknown" {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	trace.Identify(ctx, map[string]string{"uid": "42"})
+	trace.AddPoint(ctx, api.NewPoint("checked", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	trace.AddPoint(stream.Context(), api.NewPoint("watched", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	for n := 0; n < 2; n++ {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastTrace returns the last exported trace, waiting for it since the server
+// interceptor can return after the client received the response.
+func lastTrace(t *testing.T, e *testutil.Exporter) *sqgrpc.Trace {
+	var signals []api.SignalFace
+	require.Eventually(t, func() bool {
+		signals = e.Take()
+		return len(signals) > 0
+	}, time.Second, time.Millisecond)
+	return signals[len(signals)-1].(*sqgrpc.Trace)
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package health
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Descriptions of the Prometheus metrics.
+var (
+	requestsDesc = prometheus.NewDesc(
+		"sqreen_sdk_requests_total",
+		"Number of API requests by endpoint and outcome.",
+		[]string{"endpoint", "outcome"}, nil,
+	)
+	retriesDesc = prometheus.NewDesc(
+		"sqreen_sdk_retries_total",
+		"Number of retried API requests by endpoint.",
+		[]string{"endpoint"}, nil,
+	)
+	droppedDesc = prometheus.NewDesc(
+		"sqreen_sdk_dropped_signals_total",
+		"Number of dropped signals.",
+		nil, nil,
+	)
+	latencyDesc = prometheus.NewDesc(
+		"sqreen_sdk_request_duration_seconds",
+		"Duration of the API requests by endpoint.",
+		[]string{"endpoint"}, nil,
+	)
+	queueLenDesc = prometheus.NewDesc(
+		"sqreen_sdk_queue_length",
+		"Number of signals waiting to be sent.",
+		nil, nil,
+	)
+)
+
+var _ prometheus.Collector = (*Stats)(nil)
+
+func (s *Stats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- requestsDesc
+	ch <- retriesDesc
+	ch <- droppedDesc
+	ch <- latencyDesc
+	if s.QueueLen != nil {
+		ch <- queueLenDesc
+	}
+}
+
+func (s *Stats) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, n := range s.requests {
+		ch <- prometheus.MustNewConstMetric(requestsDesc, prometheus.CounterValue, float64(n), k.endpoint, k.outcome)
+	}
+	for endpoint, n := range s.retries {
+		ch <- prometheus.MustNewConstMetric(retriesDesc, prometheus.CounterValue, float64(n), endpoint)
+	}
+	ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.dropped))
+	for endpoint, h := range s.latency {
+		ch <- prometheus.MustNewConstHistogram(latencyDesc, h.count, h.sum, h.buckets(), endpoint)
+	}
+	if s.QueueLen != nil {
+		ch <- prometheus.MustNewConstMetric(queueLenDesc, prometheus.GaugeValue, float64(s.QueueLen()))
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package health provides the self-observability of the SDK: statistics of the
+// client requests, retries, dropped signals and exporter queue, exposed as a
+// Prometheus collector and as SDK metrics.
+package health
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/metrics"
+)
+
+// Request outcomes, after the client error types.
+const (
+	OutcomeSuccess       = "success"
+	OutcomeAPIError      = "api_error"
+	OutcomeAuthError     = "auth_error"
+	OutcomeInvalidSignal = "invalid_signal"
+	// OutcomeError is the outcome of requests failing without API response,
+	// such as network errors.
+	OutcomeError = "error"
+)
+
+// Names of the SDK metrics recorded into the metric store of the statistics.
+const (
+	// RequestsMetricName is the sum metric of the requests keyed by
+	// `<endpoint>:<outcome>`.
+	RequestsMetricName = "sdk.requests"
+	// RetriesMetricName is the sum metric of the retries keyed by endpoint.
+	RetriesMetricName = "sdk.retries"
+	// DroppedMetricName is the sum metric of the dropped signals, under the
+	// key `signals`.
+	DroppedMetricName = "sdk.dropped"
+	// LatencyMetricName is the binning metric of the request durations in
+	// milliseconds.
+	LatencyMetricName = "sdk.request_latency"
+	// QueueLenMetricName is the binning metric of the queue lengths sampled at
+	// every request.
+	QueueLenMetricName = "sdk.queue_length"
+)
+
+// LatencyBuckets are the upper bounds, in seconds, of the request duration
+// histogram buckets.
+var LatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Outcome returns the outcome of a request returning the given error.
+func Outcome(err error) string {
+	var (
+		authErr    client.AuthTokenError
+		invalidErr client.InvalidSignalError
+		apiErr     client.APIError
+	)
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.As(err, &authErr):
+		return OutcomeAuthError
+	case errors.As(err, &invalidErr):
+		return OutcomeInvalidSignal
+	case errors.As(err, &apiErr):
+		return OutcomeAPIError
+	default:
+		return OutcomeError
+	}
+}
+
+// Stats is a client observer keeping the statistics of the client activity
+// (cf. client.Client.Observer). It is safe for concurrent use. The zero Stats
+// is empty and ready to use.
+type Stats struct {
+	// QueueLen returns the number of signals waiting to be sent, such as
+	// client.BatchExporter.QueueLen. The queue length is not reported when
+	// nil.
+	QueueLen func() int
+	// Store is the metric store the statistics are also recorded into when
+	// not nil.
+	Store *metrics.Store
+
+	mu       sync.Mutex
+	requests map[requestKey]uint64
+	retries  map[string]uint64
+	dropped  uint64
+	latency  map[string]*histogram
+}
+
+type requestKey struct {
+	endpoint, outcome string
+}
+
+var _ client.Observer = (*Stats)(nil)
+
+// NewStats returns new empty statistics.
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+func (s *Stats) ObserveRequest(endpoint string, d time.Duration, err error) {
+	outcome := Outcome(err)
+	s.mu.Lock()
+	if s.requests == nil {
+		s.requests = make(map[requestKey]uint64)
+		s.latency = make(map[string]*histogram)
+	}
+	s.requests[requestKey{endpoint, outcome}]++
+	h := s.latency[endpoint]
+	if h == nil {
+		h = newHistogram(LatencyBuckets)
+		s.latency[endpoint] = h
+	}
+	h.observe(d.Seconds())
+	s.mu.Unlock()
+
+	if s.Store != nil {
+		s.Store.Sum(RequestsMetricName, metrics.Limit{}).Add(endpoint+":"+outcome, 1)
+		s.Store.Binning(LatencyMetricName, 2, 1).Add(float64(d) / float64(time.Millisecond))
+		if s.QueueLen != nil {
+			s.Store.Binning(QueueLenMetricName, 2, 1).Add(float64(s.QueueLen()))
+		}
+	}
+}
+
+func (s *Stats) ObserveRetry(endpoint string) {
+	s.mu.Lock()
+	if s.retries == nil {
+		s.retries = make(map[string]uint64)
+	}
+	s.retries[endpoint]++
+	s.mu.Unlock()
+
+	if s.Store != nil {
+		s.Store.Sum(RetriesMetricName, metrics.Limit{}).Add(endpoint, 1)
+	}
+}
+
+func (s *Stats) ObserveDrop(n int) {
+	s.mu.Lock()
+	s.dropped += uint64(n)
+	s.mu.Unlock()
+
+	if s.Store != nil {
+		s.Store.Sum(DroppedMetricName, metrics.Limit{}).Add("signals", int64(n))
+	}
+}
+
+// Requests returns the number of requests to the endpoint having the outcome.
+func (s *Stats) Requests(endpoint, outcome string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[requestKey{endpoint, outcome}]
+}
+
+// Retries returns the number of retried requests to the endpoint.
+func (s *Stats) Retries(endpoint string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.retries[endpoint]
+}
+
+// Dropped returns the number of dropped signals.
+func (s *Stats) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// histogram counts the observed values per bucket.
+type histogram struct {
+	bounds []float64
+	// counts are the non-cumulative bucket counts, the last one counting the
+	// values greater than every bound.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.counts[sort.SearchFloat64s(h.bounds, v)]++
+	h.count++
+	h.sum += v
+}
+
+// buckets returns the cumulative bucket counts per upper bound.
+func (h *histogram) buckets() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(h.bounds))
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		buckets[bound] = cumulative
+	}
+	return buckets
+}
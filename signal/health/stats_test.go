@@ -0,0 +1,145 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package health_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/health"
+	"github.com/sqreen/go-sdk/signal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutcome(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected string
+	}{
+		{nil, health.OutcomeSuccess},
+		{client.APIError{}, health.OutcomeAPIError},
+		{client.AuthTokenError{}, health.OutcomeAuthError},
+		{client.InvalidSignalError{}, health.OutcomeInvalidSignal},
+		{errors.New("connection refused"), health.OutcomeError},
+		{fmt.Errorf("send: %w", client.InvalidSignalError{}), health.OutcomeInvalidSignal},
+		{fmt.Errorf("send: %w", client.AuthTokenError{}), health.OutcomeAuthError},
+	} {
+		tc := tc
+		t.Run(tc.expected, func(t *testing.T) {
+			require.Equal(t, tc.expected, health.Outcome(tc.err))
+		})
+	}
+}
+
+func TestStats(t *testing.T) {
+	// The server fails the first two requests with a server error, then
+	// rejects the signals of the third one and accepts the others.
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1, 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 3:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+	}))
+	defer srv.Close()
+
+	c := client.NewClient(srv.Client(), "")
+	baseURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	c.BaseURL = baseURL
+
+	stats := health.NewStats()
+	stats.Store = metrics.NewStore("sdk", time.Minute)
+	c.Observer = stats
+
+	e := client.NewBatchExporter(c, client.BatchConfig{
+		MaxBatchSize: 2,
+		FlushPeriod:  time.Hour,
+		MaxRetries:   2,
+		RetryDelay:   time.Millisecond,
+	})
+	stats.QueueLen = e.QueueLen
+	// First batch sent after two retries, second one rejected.
+	for i := 0; i < 4; i++ {
+		e.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	}
+	require.Eventually(t, func() bool {
+		return stats.Requests("batches", health.OutcomeInvalidSignal) == 1
+	}, time.Second, time.Millisecond)
+	require.NoError(t, e.Stop(context.Background()))
+
+	require.Equal(t, uint64(2), stats.Requests("batches", health.OutcomeAPIError))
+	require.Equal(t, uint64(1), stats.Requests("batches", health.OutcomeSuccess))
+	require.Equal(t, uint64(2), stats.Retries("batches"))
+	require.Equal(t, uint64(2), stats.Dropped())
+	require.Equal(t, uint64(2), e.Dropped())
+
+	t.Run("prometheus", func(t *testing.T) {
+		expected := `
+# HELP sqreen_sdk_dropped_signals_total Number of dropped signals.
+# TYPE sqreen_sdk_dropped_signals_total counter
+sqreen_sdk_dropped_signals_total 2
+# HELP sqreen_sdk_queue_length Number of signals waiting to be sent.
+# TYPE sqreen_sdk_queue_length gauge
+sqreen_sdk_queue_length 0
+# HELP sqreen_sdk_requests_total Number of API requests by endpoint and outcome.
+# TYPE sqreen_sdk_requests_total counter
+sqreen_sdk_requests_total{endpoint="batches",outcome="api_error"} 2
+sqreen_sdk_requests_total{endpoint="batches",outcome="invalid_signal"} 1
+sqreen_sdk_requests_total{endpoint="batches",outcome="success"} 1
+# HELP sqreen_sdk_retries_total Number of retried API requests by endpoint.
+# TYPE sqreen_sdk_retries_total counter
+sqreen_sdk_retries_total{endpoint="batches"} 2
+`
+		require.NoError(t, testutil.CollectAndCompare(stats, strings.NewReader(expected),
+			"sqreen_sdk_dropped_signals_total",
+			"sqreen_sdk_queue_length",
+			"sqreen_sdk_requests_total",
+			"sqreen_sdk_retries_total",
+		))
+		require.Equal(t, 1, testutil.CollectAndCount(stats, "sqreen_sdk_request_duration_seconds"))
+	})
+
+	t.Run("sdk metrics", func(t *testing.T) {
+		values := make(map[string]interface{})
+		for _, m := range stats.Store.Flush(time.Now()) {
+			values[m.Name] = m.SignalPayload.Payload
+		}
+		require.Contains(t, values, health.LatencyMetricName)
+		require.Contains(t, values, health.QueueLenMetricName)
+		require.ElementsMatch(t, []api.MetricValueEntry{
+			{Key: "batches:api_error", Value: 2},
+			{Key: "batches:invalid_signal", Value: 1},
+			{Key: "batches:success", Value: 1},
+		}, values[health.RequestsMetricName].(api.MetricSignalPayload).Values)
+		require.Equal(t, []api.MetricValueEntry{{Key: "batches", Value: 2}},
+			values[health.RetriesMetricName].(api.MetricSignalPayload).Values)
+		require.Equal(t, []api.MetricValueEntry{{Key: "signals", Value: 2}},
+			values[health.DroppedMetricName].(api.MetricSignalPayload).Values)
+	})
+}
+
+func TestStatsZeroValue(t *testing.T) {
+	var stats health.Stats
+	require.Equal(t, uint64(0), stats.Requests("batches", health.OutcomeSuccess))
+	stats.ObserveRequest("batches", time.Millisecond, nil)
+	stats.ObserveRetry("batches")
+	require.Equal(t, uint64(1), stats.Requests("batches", health.OutcomeSuccess))
+	require.Equal(t, uint64(1), stats.Retries("batches"))
+	require.Equal(t, 1, testutil.CollectAndCount(&stats, "sqreen_sdk_request_duration_seconds"))
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"net/http"
+	"sort"
+)
+
+var (
+	// DefaultAllowedHeaders is the set of security-relevant headers captured by
+	// default: client IP headers set by proxies, content negotiation and
+	// origin headers.
+	DefaultAllowedHeaders = []string{
+		"Accept",
+		"Accept-Encoding",
+		"Accept-Language",
+		"Cf-Connecting-Ip",
+		"Content-Length",
+		"Content-Type",
+		"Fastly-Client-Ip",
+		"Forwarded",
+		"Host",
+		"Origin",
+		"Referer",
+		"True-Client-Ip",
+		"User-Agent",
+		"Via",
+		"X-Client-Ip",
+		"X-Cluster-Client-Ip",
+		"X-Forwarded",
+		"X-Forwarded-For",
+		"X-Forwarded-Host",
+		"X-Forwarded-Proto",
+		"X-Real-Ip",
+		"X-Request-Id",
+		"X-Requested-With",
+	}
+
+	// DefaultDeniedHeaders is the set of headers carrying credentials that are
+	// never captured by default.
+	DefaultDeniedHeaders = []string{
+		"Authorization",
+		"Cookie",
+		"Proxy-Authorization",
+		"Set-Cookie",
+		"X-Api-Key",
+		"X-Auth-Token",
+		"X-Csrf-Token",
+	}
+)
+
+// Default limits of the header capture.
+const (
+	DefaultMaxHeaderValueLength = 1024
+	DefaultMaxHeaderBytes       = 8 << 10
+)
+
+// HeaderPolicy is the policy of the capture of request headers into request
+// contexts. Default values are used for zero fields.
+type HeaderPolicy struct {
+	// Allow is the list of captured header names. The special name "*" allows
+	// every header. DefaultAllowedHeaders is used when nil.
+	Allow []string
+	// Deny is the list of header names never captured, even when allowed.
+	// DefaultDeniedHeaders is used when nil, so that an empty non-nil list
+	// denies no header.
+	Deny []string
+	// MaxValueLength is the maximum length of captured header values. Longer
+	// values are truncated.
+	MaxValueLength int
+	// MaxBytes is the maximum total number of bytes of captured header names
+	// and values. Headers are captured in name order until the budget is
+	// exhausted.
+	MaxBytes int
+}
+
+// DefaultHeaderPolicy is the header policy used when none is provided.
+var DefaultHeaderPolicy = &HeaderPolicy{}
+
+// Capture returns the captured header name-value pairs according to the
+// policy.
+func (p *HeaderPolicy) Capture(h http.Header) [][]string {
+	allow, allowAll := p.allowed()
+	deny := canonicalSet(p.Deny, DefaultDeniedHeaders)
+	maxValueLength := p.MaxValueLength
+	if maxValueLength <= 0 {
+		maxValueLength = DefaultMaxHeaderValueLength
+	}
+	budget := p.MaxBytes
+	if budget <= 0 {
+		budget = DefaultMaxHeaderBytes
+	}
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers [][]string
+	for _, name := range names {
+		canonical := http.CanonicalHeaderKey(name)
+		if _, denied := deny[canonical]; denied {
+			continue
+		}
+		if _, allowed := allow[canonical]; !allowed && !allowAll {
+			continue
+		}
+		for _, v := range h[name] {
+			if len(v) > maxValueLength {
+				v = v[:maxValueLength]
+			}
+			size := len(name) + len(v)
+			if size > budget {
+				return headers
+			}
+			budget -= size
+			headers = append(headers, []string{name, v})
+		}
+	}
+	return headers
+}
+
+func (p *HeaderPolicy) allowed() (set map[string]struct{}, all bool) {
+	set = canonicalSet(p.Allow, DefaultAllowedHeaders)
+	_, all = set["*"]
+	return set, all
+}
+
+func canonicalSet(names, defaults []string) map[string]struct{} {
+	if names == nil {
+		names = defaults
+	}
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if name != "*" {
+			name = http.CanonicalHeaderKey(name)
+		}
+		set[name] = struct{}{}
+	}
+	return set
+}
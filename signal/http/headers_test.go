@@ -0,0 +1,96 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderPolicy(t *testing.T) {
+	headers := http.Header{
+		"Authorization":   {"Bearer abc"},
+		"Content-Type":    {"application/json"},
+		"X-Custom":        {"custom"},
+		"X-Forwarded-For": {"1.2.3.4", "5.6.7.8"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		policy   *sqhttp.HeaderPolicy
+		expected [][]string
+	}{
+		{
+			name:   "default policy",
+			policy: sqhttp.DefaultHeaderPolicy,
+			expected: [][]string{
+				{"Content-Type", "application/json"},
+				{"X-Forwarded-For", "1.2.3.4"},
+				{"X-Forwarded-For", "5.6.7.8"},
+			},
+		},
+		{
+			name:   "allow list",
+			policy: &sqhttp.HeaderPolicy{Allow: []string{"x-custom", "authorization"}},
+			expected: [][]string{
+				{"X-Custom", "custom"},
+			},
+		},
+		{
+			name:   "allow all with empty deny list",
+			policy: &sqhttp.HeaderPolicy{Allow: []string{"*"}, Deny: []string{}},
+			expected: [][]string{
+				{"Authorization", "Bearer abc"},
+				{"Content-Type", "application/json"},
+				{"X-Custom", "custom"},
+				{"X-Forwarded-For", "1.2.3.4"},
+				{"X-Forwarded-For", "5.6.7.8"},
+			},
+		},
+		{
+			name:   "deny list replacing the default one",
+			policy: &sqhttp.HeaderPolicy{Allow: []string{"*"}, Deny: []string{"X-Forwarded-For"}},
+			expected: [][]string{
+				{"Authorization", "Bearer abc"},
+				{"Content-Type", "application/json"},
+				{"X-Custom", "custom"},
+			},
+		},
+		{
+			name:   "value truncation",
+			policy: &sqhttp.HeaderPolicy{MaxValueLength: 5},
+			expected: [][]string{
+				{"Content-Type", "appli"},
+				{"X-Forwarded-For", "1.2.3"},
+				{"X-Forwarded-For", "5.6.7"},
+			},
+		},
+		{
+			name:   "header bytes budget",
+			policy: &sqhttp.HeaderPolicy{MaxBytes: len("Content-Type") + len("application/json") + len("X-Forwarded-For") + len("1.2.3.4")},
+			expected: [][]string{
+				{"Content-Type", "application/json"},
+				{"X-Forwarded-For", "1.2.3.4"},
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.policy.Capture(headers))
+		})
+	}
+
+	t.Run("request context", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header = headers
+		reqCtx := sqhttp.NewRequestContextFromRequest(req, time.Now(), time.Now(), &sqhttp.HeaderPolicy{Allow: []string{"X-Custom"}})
+		require.Equal(t, [][]string{{"X-Custom", "custom"}}, reqCtx.Headers)
+	})
+}
@@ -8,6 +8,8 @@
 package http
 
 import (
+	"errors"
+	"math"
 	"time"
 
 	"github.com/sqreen/go-sdk/signal/client/api"
@@ -25,6 +27,10 @@ type (
 	Context struct {
 		Request  RequestContext  `json:"request"`
 		Response ResponseContext `json:"response"`
+		// Sampling is the decision of the sampler that sampled the trace.
+		Sampling *SamplingDecision `json:"sampling,omitempty"`
+		// Redacted is the list of the trace fields redacted by ScrubTrace().
+		Redacted []string `json:"redacted_fields,omitempty"`
 	}
 
 	RequestContext struct {
@@ -39,6 +45,7 @@ type (
 		RemoteIP   string      `json:"remote_ip"`
 		RemotePort uint64      `json:"remote_port"`
 		Path       string      `json:"path"`
+		Route      string      `json:"route,omitempty"`
 		Referer    string      `json:"referer"`
 		Parameters interface{} `json:"parameters"`
 		Rid        string      `json:"rid"`
@@ -74,29 +81,147 @@ func NewContext(req *RequestContext, resp *ResponseContext) *Context {
 	}
 }
 
+// NewRequestContext returns a new request context without validating it (cf.
+// BuildRequestContext()).
 func NewRequestContext(start, end time.Time, requestID string, headers [][]string, userAgent, scheme, verb, host, remoteIP, path, referer string, port, remotePort uint64, parameters interface{}) *RequestContext {
-	return &RequestContext{
-		Start:      start,
-		End:        end,
-		Rid:        requestID,
-		Headers:    headers,
-		UserAgent:  userAgent,
-		Scheme:     scheme,
-		Verb:       verb,
-		Host:       host,
-		Port:       port,
-		RemoteIP:   remoteIP,
-		RemotePort: remotePort,
-		Path:       path,
-		Referer:    referer,
-		Parameters: parameters,
-	}
+	return newRequestContext(
+		WithTimes(start, end),
+		WithRequestID(requestID),
+		WithHeaders(headers),
+		WithUserAgent(userAgent),
+		WithScheme(scheme),
+		WithVerb(verb),
+		WithHost(host, port),
+		WithRemoteAddr(remoteIP, remotePort),
+		WithPath(path),
+		WithReferer(referer),
+		WithParameters(parameters),
+	)
 }
 
+// NewResponseContext returns a new response context without validating it
+// (cf. BuildResponseContext()).
 func NewResponseContext(status int, contentType string, contentLength int64) *ResponseContext {
-	return &ResponseContext{
-		Status:        status,
-		ContentType:   contentType,
-		ContentLength: contentLength,
+	return newResponseContext(
+		WithStatus(status),
+		WithContentType(contentType),
+		WithContentLength(contentLength),
+	)
+}
+
+// RequestContextOption sets a field of the request context built by
+// BuildRequestContext().
+type RequestContextOption func(*RequestContext)
+
+func WithTimes(start, end time.Time) RequestContextOption {
+	return func(c *RequestContext) { c.Start, c.End = start, end }
+}
+
+func WithRequestID(requestID string) RequestContextOption {
+	return func(c *RequestContext) { c.Rid = requestID }
+}
+
+func WithHeaders(headers [][]string) RequestContextOption {
+	return func(c *RequestContext) { c.Headers = headers }
+}
+
+func WithUserAgent(userAgent string) RequestContextOption {
+	return func(c *RequestContext) { c.UserAgent = userAgent }
+}
+
+func WithScheme(scheme string) RequestContextOption {
+	return func(c *RequestContext) { c.Scheme = scheme }
+}
+
+func WithVerb(verb string) RequestContextOption {
+	return func(c *RequestContext) { c.Verb = verb }
+}
+
+func WithHost(host string, port uint64) RequestContextOption {
+	return func(c *RequestContext) { c.Host, c.Port = host, port }
+}
+
+func WithRemoteAddr(ip string, port uint64) RequestContextOption {
+	return func(c *RequestContext) { c.RemoteIP, c.RemotePort = ip, port }
+}
+
+func WithPath(path string) RequestContextOption {
+	return func(c *RequestContext) { c.Path = path }
+}
+
+func WithRoute(route string) RequestContextOption {
+	return func(c *RequestContext) { c.Route = route }
+}
+
+func WithReferer(referer string) RequestContextOption {
+	return func(c *RequestContext) { c.Referer = referer }
+}
+
+func WithParameters(parameters interface{}) RequestContextOption {
+	return func(c *RequestContext) { c.Parameters = parameters }
+}
+
+// Request and response context validation errors.
+var (
+	ErrMissingVerb   = errors.New("http: missing request verb")
+	ErrInvalidTimes  = errors.New("http: request end time before its start time")
+	ErrInvalidPort   = errors.New("http: invalid port number")
+	ErrInvalidStatus = errors.New("http: invalid response status code")
+)
+
+// BuildRequestContext returns the request context built with the given
+// options after validating it.
+func BuildRequestContext(opts ...RequestContextOption) (*RequestContext, error) {
+	c := newRequestContext(opts...)
+	switch {
+	case c.Verb == "":
+		return nil, ErrMissingVerb
+	case c.End.Before(c.Start):
+		return nil, ErrInvalidTimes
+	case c.Port > math.MaxUint16 || c.RemotePort > math.MaxUint16:
+		return nil, ErrInvalidPort
+	}
+	return c, nil
+}
+
+func newRequestContext(opts ...RequestContextOption) *RequestContext {
+	c := &RequestContext{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ResponseContextOption sets a field of the response context built by
+// BuildResponseContext().
+type ResponseContextOption func(*ResponseContext)
+
+func WithStatus(status int) ResponseContextOption {
+	return func(c *ResponseContext) { c.Status = status }
+}
+
+func WithContentType(contentType string) ResponseContextOption {
+	return func(c *ResponseContext) { c.ContentType = contentType }
+}
+
+func WithContentLength(contentLength int64) ResponseContextOption {
+	return func(c *ResponseContext) { c.ContentLength = contentLength }
+}
+
+// BuildResponseContext returns the response context built with the given
+// options after validating it.
+func BuildResponseContext(opts ...ResponseContextOption) (*ResponseContext, error) {
+	c := newResponseContext(opts...)
+	if c.Status < 100 || c.Status > 599 {
+		return nil, ErrInvalidStatus
+	}
+	return c, nil
+}
+
+func newResponseContext(opts ...ResponseContextOption) *ResponseContext {
+	c := &ResponseContext{}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
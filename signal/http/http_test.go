@@ -0,0 +1,71 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"testing"
+	"time"
+
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildRequestContext(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Millisecond)
+
+	t.Run("nominal", func(t *testing.T) {
+		c, err := sqhttp.BuildRequestContext(
+			sqhttp.WithTimes(start, end),
+			sqhttp.WithRequestID("my rid"),
+			sqhttp.WithHeaders([][]string{{"Accept", "*/*"}}),
+			sqhttp.WithUserAgent("my ua"),
+			sqhttp.WithScheme("https"),
+			sqhttp.WithVerb("GET"),
+			sqhttp.WithHost("example.com", 443),
+			sqhttp.WithRemoteAddr("1.2.3.4", 1234),
+			sqhttp.WithPath("/users/42"),
+			sqhttp.WithReferer("https://example.com/"),
+			sqhttp.WithParameters("my params"),
+		)
+		require.NoError(t, err)
+		expected := sqhttp.NewRequestContext(start, end, "my rid", [][]string{{"Accept", "*/*"}}, "my ua", "https", "GET", "example.com", "1.2.3.4", "/users/42", "https://example.com/", 443, 1234, "my params")
+		require.Equal(t, expected, c)
+
+		c, err = sqhttp.BuildRequestContext(sqhttp.WithVerb("GET"), sqhttp.WithRoute("/users/{id}"))
+		require.NoError(t, err)
+		require.Equal(t, "/users/{id}", c.Route)
+	})
+
+	for _, tc := range []struct {
+		name     string
+		opts     []sqhttp.RequestContextOption
+		expected error
+	}{
+		{name: "missing verb", opts: []sqhttp.RequestContextOption{sqhttp.WithPath("/")}, expected: sqhttp.ErrMissingVerb},
+		{name: "invalid times", opts: []sqhttp.RequestContextOption{sqhttp.WithVerb("GET"), sqhttp.WithTimes(end, start)}, expected: sqhttp.ErrInvalidTimes},
+		{name: "invalid port", opts: []sqhttp.RequestContextOption{sqhttp.WithVerb("GET"), sqhttp.WithHost("example.com", 1<<16)}, expected: sqhttp.ErrInvalidPort},
+		{name: "invalid remote port", opts: []sqhttp.RequestContextOption{sqhttp.WithVerb("GET"), sqhttp.WithRemoteAddr("1.2.3.4", 1<<16)}, expected: sqhttp.ErrInvalidPort},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := sqhttp.BuildRequestContext(tc.opts...)
+			require.Equal(t, tc.expected, err)
+			require.Nil(t, c)
+		})
+	}
+}
+
+func TestBuildResponseContext(t *testing.T) {
+	c, err := sqhttp.BuildResponseContext(sqhttp.WithStatus(200), sqhttp.WithContentType("text/plain"), sqhttp.WithContentLength(42))
+	require.NoError(t, err)
+	require.Equal(t, sqhttp.NewResponseContext(200, "text/plain", 42), c)
+
+	for _, status := range []int{0, 99, 600} {
+		c, err := sqhttp.BuildResponseContext(sqhttp.WithStatus(status))
+		require.Equal(t, sqhttp.ErrInvalidStatus, err)
+		require.Nil(t, c)
+	}
+}
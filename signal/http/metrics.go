@@ -0,0 +1,68 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/metrics"
+)
+
+// Server metric names.
+const (
+	StatusClassesMetricName = "http.server.status_classes"
+	VerbsMetricName         = "http.server.verbs"
+	RoutesMetricName        = "http.server.routes"
+	LatencyMetricName       = "http.server.latency_ms"
+	ResponseSizeMetricName  = "http.server.response_size_bytes"
+)
+
+// Binning parameters of the server metrics.
+const (
+	latencyBase      = 2
+	latencyUnit      = 0.1
+	responseSizeBase = 2
+	responseSizeUnit = 64
+)
+
+// ServerMetrics is the set of aggregated metrics of the HTTP server:
+// sum metrics of the response status code classes (eg. "2xx"), of the request
+// verbs and of the request route templates, and binning metrics of the request latency
+// in milliseconds and of the response size in bytes.
+type ServerMetrics struct {
+	statusClasses *metrics.Sum
+	verbs         *metrics.Sum
+	routes        *metrics.Sum
+	latency       *metrics.Binning
+	responseSize  *metrics.Binning
+}
+
+// NewServerMetrics returns the server metrics stored into the given metric
+// store. The store is expected to be flushed every metric interval (cf.
+// metrics.Store.Run()).
+func NewServerMetrics(store *metrics.Store) *ServerMetrics {
+	// Verbs and routes are client-controlled values and are collapsed when too
+	// many of them are seen.
+	return &ServerMetrics{
+		statusClasses: store.Sum(StatusClassesMetricName, metrics.Limit{}),
+		verbs:         store.Sum(VerbsMetricName, metrics.Limit{MaxKeys: 32, Overflow: metrics.OverflowCollapse}),
+		routes:        store.Sum(RoutesMetricName, metrics.Limit{Overflow: metrics.OverflowCollapse}),
+		latency:       store.Binning(LatencyMetricName, latencyBase, latencyUnit),
+		responseSize:  store.Binning(ResponseSizeMetricName, responseSizeBase, responseSizeUnit),
+	}
+}
+
+func (m *ServerMetrics) record(req *RequestContext, resp *ResponseContext) {
+	m.statusClasses.Add(statusClass(resp.Status), 1)
+	m.verbs.Add(req.Verb, 1)
+	m.routes.Add(req.Route, 1)
+	m.latency.Add(float64(req.End.Sub(req.Start)) / float64(time.Millisecond))
+	m.responseSize.Add(float64(resp.ContentLength))
+}
+
+func statusClass(status int) string {
+	return strconv.Itoa(status/100) + "xx"
+}
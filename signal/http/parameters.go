@@ -0,0 +1,440 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Parameters is the structure of the request parameters extracted by
+// ExtractParameters() and stored into RequestContext.Parameters.
+type Parameters struct {
+	// Query is the set of URL query parameters.
+	Query map[string][]string `json:"query,omitempty"`
+	// Form is the set of form fields of URL-encoded request bodies.
+	Form map[string][]string `json:"form,omitempty"`
+	// Multipart is the list of field names of multipart request bodies. Field
+	// values, which can be uploaded files, are not extracted.
+	Multipart []string `json:"multipart,omitempty"`
+	// JSON is the decoded value of JSON request bodies.
+	JSON interface{} `json:"json,omitempty"`
+	// XML is the decoded value of XML request bodies. Elements are decoded into
+	// maps of child element names to values, or into strings when they only
+	// have text. Element attributes are stored with a "@" prefix and the text
+	// of elements having children under the "#text" key. Repeated child
+	// elements are decoded into arrays.
+	XML interface{} `json:"xml,omitempty"`
+	// Path is the set of path parameters, such as the wildcards of
+	// http.ServeMux patterns.
+	Path map[string]string `json:"path,omitempty"`
+	// Truncated is true when the parameters were truncated by the limits of the
+	// extraction.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Default limits of the parameter extraction.
+const (
+	DefaultMaxBodyPeek     = 16 << 10
+	DefaultMaxDepth        = 8
+	DefaultMaxValues       = 256
+	DefaultMaxStringLength = 1024
+)
+
+// ParametersConfig is the set of limits of the parameter extraction. Default
+// values are used for zero fields.
+type ParametersConfig struct {
+	// MaxBodyPeek is the maximum number of body bytes read in order to extract
+	// body parameters. Bodies larger than this are not decoded, except
+	// multipart ones whose field names are extracted until the limit.
+	MaxBodyPeek int64
+	// MaxDepth is the maximum nesting depth of the extracted values.
+	MaxDepth int
+	// MaxValues is the maximum number of values extracted per parameter kind.
+	MaxValues int
+	// MaxStringLength is the maximum length of extracted strings.
+	MaxStringLength int
+}
+
+func (c ParametersConfig) withDefaults() ParametersConfig {
+	if c.MaxBodyPeek <= 0 {
+		c.MaxBodyPeek = DefaultMaxBodyPeek
+	}
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = DefaultMaxDepth
+	}
+	if c.MaxValues <= 0 {
+		c.MaxValues = DefaultMaxValues
+	}
+	if c.MaxStringLength <= 0 {
+		c.MaxStringLength = DefaultMaxStringLength
+	}
+	return c
+}
+
+// ExtractParameters returns the query and body parameters of the request
+// according to the given limits. It must be called before the request is
+// served as it peeks at the request body without consuming it: r.Body is
+// replaced by a reader returning the peeked bytes followed by the rest of the
+// body. Path parameters are only known once the request was routed and are
+// extracted by PathParameters().
+func ExtractParameters(r *http.Request, cfg ParametersConfig) *Parameters {
+	cfg = cfg.withDefaults()
+	p := &Parameters{}
+
+	l := newLimiter(cfg)
+	p.Query = l.values(r.URL.Query())
+	p.Truncated = l.truncated
+
+	if r.Body == nil || r.Body == http.NoBody {
+		return p
+	}
+	mediaType, mediaParams, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return p
+	}
+	kind := bodyKind(mediaType)
+	if kind == bodyUnknown {
+		return p
+	}
+
+	body, complete := peekBody(r, cfg.MaxBodyPeek)
+	if !complete {
+		p.Truncated = true
+	}
+
+	l = newLimiter(cfg)
+	switch kind {
+	case bodyForm:
+		if !complete {
+			break
+		}
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			p.Form = l.values(values)
+		}
+	case bodyMultipart:
+		p.Multipart = l.multipartNames(body, mediaParams["boundary"])
+	case bodyJSON:
+		if !complete {
+			break
+		}
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err == nil {
+			p.JSON = l.limit(v, 0)
+		}
+	case bodyXML:
+		if !complete {
+			break
+		}
+		if v, err := l.decodeXML(body); err == nil {
+			p.XML = v
+		}
+	}
+	p.Truncated = p.Truncated || l.truncated
+	return p
+}
+
+// jsonSize returns the estimated memory size of the value, ie. the size of its
+// JSON representation.
+func jsonSize(v interface{}) int64 {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(buf))
+}
+
+// PathParameters returns the path parameters of the request according to the
+// wildcards of the http.ServeMux pattern that served the request, or nil when
+// the request was not served by a http.ServeMux or has no wildcards.
+func PathParameters(r *http.Request) map[string]string {
+	var params map[string]string
+	for pattern := r.Pattern; ; {
+		i := strings.IndexByte(pattern, '{')
+		if i < 0 {
+			return params
+		}
+		pattern = pattern[i+1:]
+		j := strings.IndexByte(pattern, '}')
+		if j < 0 {
+			return params
+		}
+		name := strings.TrimSuffix(pattern[:j], "...")
+		pattern = pattern[j+1:]
+		if name == "$" {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = r.PathValue(name)
+	}
+}
+
+type bodyKindType int
+
+const (
+	bodyUnknown bodyKindType = iota
+	bodyForm
+	bodyMultipart
+	bodyJSON
+	bodyXML
+)
+
+func bodyKind(mediaType string) bodyKindType {
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return bodyForm
+	case mediaType == "multipart/form-data":
+		return bodyMultipart
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return bodyJSON
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return bodyXML
+	default:
+		return bodyUnknown
+	}
+}
+
+// peekBody reads at most max bytes of the request body and replaces it with a
+// body returning the read bytes followed by the rest of the body. It returns
+// false when the body is larger than max.
+func peekBody(r *http.Request, max int64) (peeked []byte, complete bool) {
+	read, err := io.ReadAll(io.LimitReader(r.Body, max+1))
+	r.Body = &peekedBody{
+		Reader: io.MultiReader(bytes.NewReader(read), errReader{err}, r.Body),
+		Closer: r.Body,
+	}
+	if err != nil {
+		return nil, false
+	}
+	if int64(len(read)) > max {
+		return read[:max], false
+	}
+	return read, true
+}
+
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// errReader returns the error that interrupted the body peek, if any, so that
+// the handler reading the body gets it too.
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	return 0, io.EOF
+}
+
+// limiter limits the extracted values according to the configuration.
+type limiter struct {
+	cfg       ParametersConfig
+	n         int
+	truncated bool
+}
+
+func newLimiter(cfg ParametersConfig) *limiter {
+	return &limiter{cfg: cfg}
+}
+
+func (l *limiter) full() bool {
+	if l.n >= l.cfg.MaxValues {
+		l.truncated = true
+		return true
+	}
+	return false
+}
+
+func (l *limiter) string(s string) string {
+	if len(s) > l.cfg.MaxStringLength {
+		l.truncated = true
+		return s[:l.cfg.MaxStringLength]
+	}
+	return s
+}
+
+func (l *limiter) values(values url.Values) map[string][]string {
+	if len(values) == 0 {
+		return nil
+	}
+	limited := make(map[string][]string, len(values))
+	for _, k := range sortedKeys(values) {
+		for _, v := range values[k] {
+			if l.full() {
+				return limited
+			}
+			l.n++
+			key := l.string(k)
+			limited[key] = append(limited[key], l.string(v))
+		}
+	}
+	return limited
+}
+
+// limit returns the value limited in depth, number of values and string
+// lengths. Values are expected to be decoded JSON values.
+func (l *limiter) limit(v interface{}, depth int) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if depth >= l.cfg.MaxDepth {
+			l.truncated = true
+			return nil
+		}
+		limited := make(map[string]interface{}, len(v))
+		for _, k := range sortedKeys(v) {
+			if l.full() {
+				break
+			}
+			limited[l.string(k)] = l.limit(v[k], depth+1)
+		}
+		return limited
+	case []interface{}:
+		if depth >= l.cfg.MaxDepth {
+			l.truncated = true
+			return nil
+		}
+		limited := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			if l.full() {
+				break
+			}
+			limited = append(limited, l.limit(e, depth+1))
+		}
+		return limited
+	case string:
+		l.n++
+		return l.string(v)
+	default:
+		l.n++
+		return v
+	}
+}
+
+func (l *limiter) multipartNames(body []byte, boundary string) []string {
+	if boundary == "" {
+		return nil
+	}
+	var names []string
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for !l.full() {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		if name := part.FormName(); name != "" {
+			l.n++
+			names = append(names, l.string(name))
+		}
+	}
+	return names
+}
+
+func (l *limiter) decodeXML(body []byte) (interface{}, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			v, err := l.decodeXMLElement(d, start, 1)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{l.string(start.Name.Local): v}, nil
+		}
+	}
+}
+
+func (l *limiter) decodeXMLElement(d *xml.Decoder, start xml.StartElement, depth int) (interface{}, error) {
+	if depth >= l.cfg.MaxDepth {
+		l.truncated = true
+		return nil, d.Skip()
+	}
+
+	element := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		if l.full() {
+			break
+		}
+		l.n++
+		element["@"+l.string(attr.Name.Local)] = l.string(attr.Value)
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if l.full() {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			child, err := l.decodeXMLElement(d, tok, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name := l.string(tok.Name.Local)
+			switch prev := element[name].(type) {
+			case nil:
+				element[name] = child
+			case []interface{}:
+				element[name] = append(prev, child)
+			default:
+				element[name] = []interface{}{prev, child}
+			}
+
+		case xml.CharData:
+			text.Write(tok)
+
+		case xml.EndElement:
+			s := strings.TrimSpace(text.String())
+			if len(element) == 0 {
+				l.n++
+				return l.string(s), nil
+			}
+			if s != "" && !l.full() {
+				l.n++
+				element["#text"] = l.string(s)
+			}
+			return element, nil
+		}
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case url.Values:
+		keys = make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]interface{}:
+		keys = make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
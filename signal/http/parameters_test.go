@@ -0,0 +1,150 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractParameters(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	require.NoError(t, mw.WriteField("name", "value"))
+	fw, err := mw.CreateFormFile("file", "file.txt")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("file content"))
+	require.NoError(t, mw.Close())
+
+	for _, tc := range []struct {
+		name        string
+		url         string
+		contentType string
+		body        string
+		cfg         sqhttp.ParametersConfig
+		expected    *sqhttp.Parameters
+	}{
+		{
+			name:     "query",
+			url:      "/?a=1&b=2&a=3",
+			expected: &sqhttp.Parameters{Query: map[string][]string{"a": {"1", "3"}, "b": {"2"}}},
+		},
+		{
+			name:     "query limits",
+			url:      "/?a=1&b=2&a=3&c=hello",
+			cfg:      sqhttp.ParametersConfig{MaxValues: 3, MaxStringLength: 4},
+			expected: &sqhttp.Parameters{Query: map[string][]string{"a": {"1", "3"}, "b": {"2"}}, Truncated: true},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "a=1&b=2",
+			expected:    &sqhttp.Parameters{Form: map[string][]string{"a": {"1"}, "b": {"2"}}},
+		},
+		{
+			name:        "multipart",
+			contentType: mw.FormDataContentType(),
+			body:        multipartBody.String(),
+			expected:    &sqhttp.Parameters{Multipart: []string{"name", "file"}},
+		},
+		{
+			name:        "json",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"a":[1,"two",{"b":true}],"c":null}`,
+			expected: &sqhttp.Parameters{JSON: map[string]interface{}{
+				"a": []interface{}{1.0, "two", map[string]interface{}{"b": true}},
+				"c": nil,
+			}},
+		},
+		{
+			name:        "json depth limit",
+			contentType: "application/json",
+			body:        `{"a":{"b":{"c":1}},"d":2}`,
+			cfg:         sqhttp.ParametersConfig{MaxDepth: 2},
+			expected: &sqhttp.Parameters{
+				JSON:      map[string]interface{}{"a": map[string]interface{}{"b": nil}, "d": 2.0},
+				Truncated: true,
+			},
+		},
+		{
+			name:        "json larger than the body peek",
+			contentType: "application/json",
+			body:        `{"a":"hello"}`,
+			cfg:         sqhttp.ParametersConfig{MaxBodyPeek: 4},
+			expected:    &sqhttp.Parameters{Truncated: true},
+		},
+		{
+			name:        "xml",
+			contentType: "application/xml",
+			body:        `<user id="1"><name>bob</name><role>admin</role><role>dev</role></user>`,
+			expected: &sqhttp.Parameters{XML: map[string]interface{}{
+				"user": map[string]interface{}{
+					"@id":  "1",
+					"name": "bob",
+					"role": []interface{}{"admin", "dev"},
+				},
+			}},
+		},
+		{
+			name:        "unknown content type",
+			contentType: "application/octet-stream",
+			body:        "hello",
+			expected:    &sqhttp.Parameters{},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			url := tc.url
+			if url == "" {
+				url = "/"
+			}
+			req := httptest.NewRequest("POST", url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			params := sqhttp.ExtractParameters(req, tc.cfg)
+			require.Equal(t, tc.expected, params)
+
+			// The body must be left intact
+			body, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.body, string(body))
+		})
+	}
+}
+
+func TestHandlerParameters(t *testing.T) {
+	var exporter testutil.Exporter
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /users/{id}/files/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, `{"a":1}`, string(body))
+	})
+	h := sqhttp.NewHandler(mux, &exporter)
+	h.Parameters = &sqhttp.ParametersConfig{}
+
+	req := httptest.NewRequest("POST", "/users/123/files/a/b.txt?q=1", strings.NewReader(`{"a":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	traces := exportedTraces(&exporter)
+	require.Len(t, traces, 1)
+	require.Equal(t, &sqhttp.Parameters{
+		Query: map[string][]string{"q": {"1"}},
+		JSON:  map[string]interface{}{"a": 1.0},
+		Path:  map[string]string{"id": "123", "path": "a/b.txt"},
+	}, traces[0].Context.(*sqhttp.Context).Request.Parameters)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"time"
+)
+
+// Retention decision reasons.
+const (
+	RetentionReasonStatus  = "retention:status"
+	RetentionReasonLatency = "retention:latency"
+	RetentionReasonPoint   = "retention:point"
+	RetentionReasonActor   = "retention:actor"
+)
+
+// RetentionPolicy is a tail-based sampler deciding at request completion
+// whether the trace is kept according to its response status code, its
+// latency, the names of its point signals or its actor identifiers. Traces
+// matching none of the criteria are sampled by Fallback. Zero criteria are
+// disabled.
+//
+// Since the trace of every request is buffered until its completion, the
+// memory used by the buffered signals and parameters is bounded by the
+// middleware handler Budget, the rest of the trace context being bounded by the
+// header policy.
+type RetentionPolicy struct {
+	// MinStatus keeps the traces whose response status code is greater than or
+	// equal to it, eg. 500 to keep server errors.
+	MinStatus int
+	// StatusCodes keeps the traces whose response status code is one of them.
+	StatusCodes []int
+	// Latency keeps the traces of requests that took at least this duration.
+	Latency time.Duration
+	// PointNames keeps the traces having a point signal with one of these
+	// names.
+	PointNames []string
+	// ActorIdentifiers keeps the traces whose actor has one of these
+	// identifiers. The identifier values are matched when the list of values
+	// is not empty, otherwise the presence of the identifier key is enough.
+	ActorIdentifiers map[string][]string
+	// Fallback samples the traces matching none of the criteria. They are
+	// dropped when nil.
+	Fallback Sampler
+}
+
+func (p *RetentionPolicy) Sample(t *Trace) SamplingDecision {
+	if reason, keep := p.keep(t); keep {
+		return SamplingDecision{Sampled: true, Rate: 1, Reason: reason}
+	}
+	if p.Fallback == nil {
+		return SamplingDecision{Sampled: false}
+	}
+	return p.Fallback.Sample(t)
+}
+
+func (p *RetentionPolicy) keep(t *Trace) (reason string, keep bool) {
+	if c := traceContext(t); c != nil {
+		status := c.Response.Status
+		if p.MinStatus > 0 && status >= p.MinStatus {
+			return RetentionReasonStatus, true
+		}
+		for _, code := range p.StatusCodes {
+			if status == code {
+				return RetentionReasonStatus, true
+			}
+		}
+		if p.Latency > 0 && c.Request.End.Sub(c.Request.Start) >= p.Latency {
+			return RetentionReasonLatency, true
+		}
+	}
+
+	if len(p.PointNames) > 0 {
+		for _, s := range t.Data {
+			if s == nil || s.Type != "point" {
+				continue
+			}
+			for _, name := range p.PointNames {
+				if s.Name == name {
+					return RetentionReasonPoint, true
+				}
+			}
+		}
+	}
+
+	if actor, ok := t.Actor.(*Actor); ok && actor != nil && len(p.ActorIdentifiers) > 0 {
+		for key, values := range p.ActorIdentifiers {
+			id, exists := actor.Identifiers[key]
+			if !exists {
+				continue
+			}
+			if len(values) == 0 {
+				return RetentionReasonActor, true
+			}
+			for _, v := range values {
+				if id == v {
+					return RetentionReasonActor, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
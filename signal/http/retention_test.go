@@ -0,0 +1,118 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionPolicy(t *testing.T) {
+	start := time.Now()
+	newTrace := func(status int, latency time.Duration, ids map[string]string, signals ...*api.Signal) *sqhttp.Trace {
+		req := &sqhttp.RequestContext{Start: start, End: start.Add(latency)}
+		resp := sqhttp.NewResponseContext(status, "", 0)
+		actor := sqhttp.NewActor(nil, "", ids)
+		return sqhttp.NewTrace("test", start, actor, nil, sqhttp.NewContext(req, resp), signals)
+	}
+	point := func(name string) *api.Signal {
+		return (*api.Signal)(api.NewPoint(name, "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	}
+
+	policy := &sqhttp.RetentionPolicy{
+		MinStatus:        500,
+		StatusCodes:      []int{401, 403},
+		Latency:          time.Second,
+		PointNames:       []string{"sql_injection"},
+		ActorIdentifiers: map[string][]string{"user_id": {"42"}, "admin": nil},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		trace    *sqhttp.Trace
+		expected string
+	}{
+		{name: "nominal", trace: newTrace(200, time.Millisecond, nil)},
+		{name: "min status", trace: newTrace(502, time.Millisecond, nil), expected: sqhttp.RetentionReasonStatus},
+		{name: "status code", trace: newTrace(403, time.Millisecond, nil), expected: sqhttp.RetentionReasonStatus},
+		{name: "other status code", trace: newTrace(404, time.Millisecond, nil)},
+		{name: "latency", trace: newTrace(200, 2*time.Second, nil), expected: sqhttp.RetentionReasonLatency},
+		{name: "point name", trace: newTrace(200, time.Millisecond, nil, point("xss"), point("sql_injection")), expected: sqhttp.RetentionReasonPoint},
+		{name: "other point name", trace: newTrace(200, time.Millisecond, nil, point("xss"))},
+		{name: "actor identifier value", trace: newTrace(200, time.Millisecond, map[string]string{"user_id": "42"}), expected: sqhttp.RetentionReasonActor},
+		{name: "other actor identifier value", trace: newTrace(200, time.Millisecond, map[string]string{"user_id": "43"})},
+		{name: "actor identifier key", trace: newTrace(200, time.Millisecond, map[string]string{"admin": "bob"}), expected: sqhttp.RetentionReasonActor},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			decision := policy.Sample(tc.trace)
+			require.Equal(t, tc.expected != "", decision.Sampled)
+			require.Equal(t, tc.expected, decision.Reason)
+		})
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		policy := &sqhttp.RetentionPolicy{MinStatus: 500, Fallback: sqhttp.NewFixedRateSampler(1)}
+		require.Equal(t, sqhttp.SamplingReasonRate, policy.Sample(newTrace(200, 0, nil)).Reason)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		var exporter testutil.Exporter
+		budget := trace.NewBudget(1, 0)
+		h := sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace.Identify(r.Context(), map[string]string{"user_id": r.URL.Query().Get("uid")})
+			require.True(t, trace.AddPoint(r.Context(), api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil)))
+			// The budget is exhausted
+			require.False(t, trace.AddPoint(r.Context(), api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil)))
+			require.Equal(t, 1, budget.Used())
+		}), &exporter)
+		h.Budget = budget
+		h.Sampler = &sqhttp.RetentionPolicy{ActorIdentifiers: map[string][]string{"user_id": {"42"}}}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?uid=1", nil))
+		require.Equal(t, 0, budget.Used())
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?uid=42", nil))
+		require.Equal(t, 0, budget.Used())
+
+		traces := exportedTraces(&exporter)
+		require.Len(t, traces, 1)
+		require.Equal(t, map[string]string{"user_id": "42"}, traces[0].Actor.(*sqhttp.Actor).Identifiers)
+		require.Len(t, traces[0].Data, 1)
+	})
+
+	t.Run("handler parameters budget", func(t *testing.T) {
+		var exporter testutil.Exporter
+		budget := trace.NewBudget(0, 64)
+		var used int64
+		h := sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			used = budget.UsedBytes()
+		}), &exporter)
+		h.Budget = budget
+		h.Parameters = &sqhttp.ParametersConfig{}
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?a=b", nil))
+		require.NotZero(t, used)
+		require.Zero(t, budget.UsedBytes())
+		traces := exportedTraces(&exporter)
+		require.Len(t, traces, 1)
+		require.NotNil(t, traces[0].Context.(*sqhttp.Context).Request.Parameters)
+
+		// The parameters exceeding the budget are dropped
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?a="+strings.Repeat("b", 64), nil))
+		require.Zero(t, used)
+		traces = exportedTraces(&exporter)
+		require.Len(t, traces, 2)
+		require.Nil(t, traces[1].Context.(*sqhttp.Context).Request.Parameters)
+	})
+}
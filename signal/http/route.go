@@ -0,0 +1,80 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// RouteResolver is the interface of the resolvers of request route templates,
+// such as "/users/{id}" for request path "/users/123". It allows to adapt
+// third-party routers. It is called once the request was served.
+type RouteResolver interface {
+	// Route returns the route template of the request, or false when the
+	// request didn't match any route.
+	Route(r *http.Request) (route string, ok bool)
+}
+
+// RouteResolverFunc is a function implementing RouteResolver.
+type RouteResolverFunc func(r *http.Request) (route string, ok bool)
+
+func (f RouteResolverFunc) Route(r *http.Request) (string, bool) { return f(r) }
+
+// DefaultRouteResolver is the route resolver of the pattern of the
+// http.ServeMux that served the request, as set in the request by the
+// http.ServeMux.
+var DefaultRouteResolver RouteResolver = RouteResolverFunc(func(r *http.Request) (string, bool) {
+	return routeFromPattern(r.Pattern)
+})
+
+// NewServeMuxRouteResolver returns the route resolver matching the request
+// against the patterns of the given http.ServeMux. It allows to resolve routes
+// of muxes that are not directly served by the middleware handler, such as
+// sub-routers.
+func NewServeMuxRouteResolver(mux *http.ServeMux) RouteResolver {
+	return RouteResolverFunc(func(r *http.Request) (string, bool) {
+		_, pattern := mux.Handler(r)
+		return routeFromPattern(pattern)
+	})
+}
+
+// routeFromPattern returns the route of a http.ServeMux pattern by removing its
+// optional method.
+func routeFromPattern(pattern string) (string, bool) {
+	if pattern == "" {
+		return "", false
+	}
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i:], " \t")
+	}
+	return pattern, true
+}
+
+var (
+	uuidRegexp   = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hashRegexp   = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	numberRegexp = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// NormalizePath returns a route template from the given path by replacing
+// segments looking like identifiers with placeholders: numeric IDs with
+// "{id}", UUIDs with "{uuid}" and hexadecimal hashes of at least 16
+// characters with "{hash}". It is the fallback of route resolvers.
+func NormalizePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		switch {
+		case numberRegexp.MatchString(s):
+			segments[i] = "{id}"
+		case uuidRegexp.MatchString(s):
+			segments[i] = "{uuid}"
+		case hashRegexp.MatchString(s):
+			segments[i] = "{hash}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizePath(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{path: "", expected: ""},
+		{path: "/", expected: "/"},
+		{path: "/users", expected: "/users"},
+		{path: "/users/123", expected: "/users/{id}"},
+		{path: "/users/123/orders/456/", expected: "/users/{id}/orders/{id}/"},
+		{path: "/users/123e4567-e89b-12d3-a456-426614174000", expected: "/users/{uuid}"},
+		{path: "/blobs/d41d8cd98f00b204e9800998ecf8427e", expected: "/blobs/{hash}"},
+		{path: "/blobs/deadbeef", expected: "/blobs/deadbeef"},
+		{path: "/v1/users", expected: "/v1/users"},
+	} {
+		tc := tc
+		t.Run(tc.path, func(t *testing.T) {
+			require.Equal(t, tc.expected, sqhttp.NormalizePath(tc.path))
+		})
+	}
+}
+
+func TestRouteResolver(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(http.ResponseWriter, *http.Request) {})
+	mux.HandleFunc("/static/", func(http.ResponseWriter, *http.Request) {})
+
+	for _, tc := range []struct {
+		name     string
+		resolver sqhttp.RouteResolver
+		path     string
+		expected string
+	}{
+		{
+			name:     "default resolver with method pattern",
+			path:     "/users/123",
+			expected: "/users/{id}",
+		},
+		{
+			name:     "default resolver with prefix pattern",
+			path:     "/static/css/main.css",
+			expected: "/static/",
+		},
+		{
+			name:     "default resolver fallback",
+			path:     "/unknown/42",
+			expected: "/unknown/{id}",
+		},
+		{
+			name:     "servemux resolver",
+			resolver: sqhttp.NewServeMuxRouteResolver(mux),
+			path:     "/users/123",
+			expected: "/users/{id}",
+		},
+		{
+			name: "custom resolver",
+			resolver: sqhttp.RouteResolverFunc(func(r *http.Request) (string, bool) {
+				return "/custom", true
+			}),
+			path:     "/users/123",
+			expected: "/custom",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var exporter testutil.Exporter
+			next := http.Handler(mux)
+			if tc.resolver != nil {
+				// Hide the mux from the default resolver.
+				next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			}
+			h := sqhttp.NewHandler(next, &exporter)
+			h.RouteResolver = tc.resolver
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.path, nil))
+
+			traces := exportedTraces(&exporter)
+			require.Len(t, traces, 1)
+			require.Equal(t, tc.expected, traces[0].Context.(*sqhttp.Context).Request.Route)
+		})
+	}
+}
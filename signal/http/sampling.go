@@ -0,0 +1,173 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Sampler decides whether the trace of a request is exported. It is consulted
+// once the request was served so that the decision can depend on the whole
+// trace. It must be safe for concurrent use.
+type Sampler interface {
+	Sample(t *Trace) SamplingDecision
+}
+
+// SamplerFunc is a function implementing Sampler.
+type SamplerFunc func(t *Trace) SamplingDecision
+
+func (f SamplerFunc) Sample(t *Trace) SamplingDecision { return f(t) }
+
+// SamplingDecision is the decision of a sampler. It is recorded on the
+// exported traces into the context field Sampling.
+type SamplingDecision struct {
+	// Sampled is true when the trace is exported.
+	Sampled bool `json:"sampled"`
+	// Rate is the probability the trace had to be sampled, when known, so that
+	// aggregations can be extrapolated from the sampled traces.
+	Rate float64 `json:"rate,omitempty"`
+	// Reason is the name of the sampling rule that took the decision.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Sampling decision reasons.
+const (
+	SamplingReasonRate         = "rate"
+	SamplingReasonRouteRate    = "route_rate"
+	SamplingReasonRateLimit    = "rate_limit"
+	SamplingReasonSignals      = "always_keep:signals"
+	SamplingReasonServerErrors = "always_keep:5xx"
+)
+
+// NewFixedRateSampler returns a sampler sampling traces with the given
+// probability between 0 and 1.
+func NewFixedRateSampler(rate float64) Sampler {
+	return SamplerFunc(func(*Trace) SamplingDecision {
+		return sampleRate(rate, SamplingReasonRate)
+	})
+}
+
+func sampleRate(rate float64, reason string) SamplingDecision {
+	return SamplingDecision{
+		Sampled: rate >= 1 || rand.Float64() < rate,
+		Rate:    rate,
+		Reason:  reason,
+	}
+}
+
+// NewRouteRateSampler returns a sampler sampling traces with the probability
+// associated with their route template (cf. RequestContext.Route). Traces of
+// other routes are sampled by the default sampler, or always sampled when nil.
+func NewRouteRateSampler(rates map[string]float64, defaultSampler Sampler) Sampler {
+	return SamplerFunc(func(t *Trace) SamplingDecision {
+		if c := traceContext(t); c != nil {
+			if rate, exists := rates[c.Request.Route]; exists {
+				return sampleRate(rate, SamplingReasonRouteRate)
+			}
+		}
+		if defaultSampler == nil {
+			return SamplingDecision{Sampled: true}
+		}
+		return defaultSampler.Sample(t)
+	})
+}
+
+// TokenBucketSampler is a sampler limiting the number of sampled traces per
+// second using a token bucket.
+type TokenBucketSampler struct {
+	perSecond float64
+	burst     float64
+	mu        sync.Mutex
+	tokens    float64
+	last      time.Time
+}
+
+// NewTokenBucketSampler returns a sampler sampling at most perSecond traces per
+// second on average, with bursts of at most burst traces.
+func NewTokenBucketSampler(perSecond float64, burst int) *TokenBucketSampler {
+	return &TokenBucketSampler{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+func (s *TokenBucketSampler) Sample(*Trace) SamplingDecision {
+	return SamplingDecision{
+		Sampled: s.take(time.Now()),
+		Reason:  SamplingReasonRateLimit,
+	}
+}
+
+func (s *TokenBucketSampler) take(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elapsed := now.Sub(s.last); elapsed > 0 {
+		s.tokens += elapsed.Seconds() * s.perSecond
+		if s.tokens > s.burst {
+			s.tokens = s.burst
+		}
+		s.last = now
+	}
+	if s.tokens < 1 {
+		return false
+	}
+	s.tokens--
+	return true
+}
+
+// KeepRule is a rule forcing the sampling of traces. It returns the reason of
+// the decision and true when the trace must be kept.
+type KeepRule func(t *Trace) (reason string, keep bool)
+
+// KeepSignals is the keep rule of the traces having at least one point signal.
+func KeepSignals(t *Trace) (string, bool) {
+	for _, s := range t.Data {
+		if s != nil && s.Type == "point" {
+			return SamplingReasonSignals, true
+		}
+	}
+	return "", false
+}
+
+// KeepServerErrors is the keep rule of the traces of 5xx responses.
+func KeepServerErrors(t *Trace) (string, bool) {
+	if c := traceContext(t); c != nil && c.Response.Status >= 500 && c.Response.Status <= 599 {
+		return SamplingReasonServerErrors, true
+	}
+	return "", false
+}
+
+// NewAlwaysKeepSampler returns a sampler always sampling the traces matching
+// one of the given keep rules, and delegating the decision to the given
+// sampler otherwise, or always sampling when nil. KeepSignals and
+// KeepServerErrors are used when no rules are given.
+func NewAlwaysKeepSampler(sampler Sampler, rules ...KeepRule) Sampler {
+	if len(rules) == 0 {
+		rules = []KeepRule{KeepSignals, KeepServerErrors}
+	}
+	return SamplerFunc(func(t *Trace) SamplingDecision {
+		for _, rule := range rules {
+			if reason, keep := rule(t); keep {
+				return SamplingDecision{Sampled: true, Rate: 1, Reason: reason}
+			}
+		}
+		if sampler == nil {
+			return SamplingDecision{Sampled: true}
+		}
+		return sampler.Sample(t)
+	})
+}
+
+func traceContext(t *Trace) *Context {
+	if t.SignalContext == nil {
+		return nil
+	}
+	c, _ := t.Context.(*Context)
+	return c
+}
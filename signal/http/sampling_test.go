@@ -0,0 +1,89 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSamplers(t *testing.T) {
+	newTrace := func(route string, status int, signals ...*api.Signal) *sqhttp.Trace {
+		req := &sqhttp.RequestContext{Route: route}
+		resp := sqhttp.NewResponseContext(status, "", 0)
+		return sqhttp.NewTrace("test", time.Now(), nil, nil, sqhttp.NewContext(req, resp), signals)
+	}
+	point := (*api.Signal)(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+
+	t.Run("fixed rate", func(t *testing.T) {
+		require.Equal(t, sqhttp.SamplingDecision{Sampled: true, Rate: 1, Reason: sqhttp.SamplingReasonRate}, sqhttp.NewFixedRateSampler(1).Sample(newTrace("/", 200)))
+		require.Equal(t, sqhttp.SamplingDecision{Sampled: false, Rate: 0, Reason: sqhttp.SamplingReasonRate}, sqhttp.NewFixedRateSampler(0).Sample(newTrace("/", 200)))
+	})
+
+	t.Run("route rate", func(t *testing.T) {
+		s := sqhttp.NewRouteRateSampler(map[string]float64{"/health": 0}, sqhttp.NewFixedRateSampler(1))
+		require.False(t, s.Sample(newTrace("/health", 200)).Sampled)
+		require.Equal(t, sqhttp.SamplingReasonRouteRate, s.Sample(newTrace("/health", 200)).Reason)
+		require.True(t, s.Sample(newTrace("/users/{id}", 200)).Sampled)
+
+		t.Run("without default sampler", func(t *testing.T) {
+			s := sqhttp.NewRouteRateSampler(map[string]float64{"/health": 0}, nil)
+			require.True(t, s.Sample(newTrace("/users/{id}", 200)).Sampled)
+		})
+	})
+
+	t.Run("token bucket", func(t *testing.T) {
+		s := sqhttp.NewTokenBucketSampler(0.001, 2)
+		require.True(t, s.Sample(newTrace("/", 200)).Sampled)
+		require.True(t, s.Sample(newTrace("/", 200)).Sampled)
+		decision := s.Sample(newTrace("/", 200))
+		require.Equal(t, sqhttp.SamplingDecision{Sampled: false, Reason: sqhttp.SamplingReasonRateLimit}, decision)
+	})
+
+	t.Run("always keep", func(t *testing.T) {
+		s := sqhttp.NewAlwaysKeepSampler(sqhttp.NewFixedRateSampler(0))
+		require.False(t, s.Sample(newTrace("/", 200)).Sampled)
+		require.Equal(t, sqhttp.SamplingDecision{Sampled: true, Rate: 1, Reason: sqhttp.SamplingReasonSignals}, s.Sample(newTrace("/", 200, point)))
+		require.Equal(t, sqhttp.SamplingDecision{Sampled: true, Rate: 1, Reason: sqhttp.SamplingReasonServerErrors}, s.Sample(newTrace("/", 503)))
+
+		t.Run("custom rules", func(t *testing.T) {
+			s := sqhttp.NewAlwaysKeepSampler(sqhttp.NewFixedRateSampler(0), sqhttp.KeepServerErrors)
+			require.False(t, s.Sample(newTrace("/", 200, point)).Sampled)
+		})
+
+		t.Run("nil sampler", func(t *testing.T) {
+			s := sqhttp.NewAlwaysKeepSampler(nil)
+			require.Equal(t, sqhttp.SamplingDecision{Sampled: true}, s.Sample(newTrace("/", 200)))
+			require.Equal(t, sqhttp.SamplingDecision{Sampled: true, Rate: 1, Reason: sqhttp.SamplingReasonSignals}, s.Sample(newTrace("/", 200, point)))
+		})
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		var exporter testutil.Exporter
+		h := sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/attack" {
+				trace.AddPoint(r.Context(), api.NewPoint("attack", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+			}
+		}), &exporter)
+		h.Sampler = sqhttp.NewAlwaysKeepSampler(sqhttp.NewFixedRateSampler(0))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/attack", nil))
+
+		traces := exportedTraces(&exporter)
+		require.Len(t, traces, 1)
+		ctx := traces[0].Context.(*sqhttp.Context)
+		require.Equal(t, "/attack", ctx.Request.Path)
+		require.Equal(t, &sqhttp.SamplingDecision{Sampled: true, Rate: 1, Reason: sqhttp.SamplingReasonSignals}, ctx.Sampling)
+	})
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"strconv"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/scrub"
+)
+
+// ScrubTrace scrubs the request headers, parameters, referer, path and route
+// of the HTTP context of the trace, along with the payloads of the trace
+// signals. The context and signals are replaced by scrubbed copies. The paths
+// of the redacted fields are recorded into the context field Redacted, with
+// paths relative to the trace, eg. "context.request.headers.Authorization" or
+// "data[0].payload.password".
+func ScrubTrace(t *Trace, s *scrub.Scrubber) {
+	var report scrub.Report
+
+	var scrubbed *Context
+	if t.SignalContext != nil {
+		if c, ok := t.Context.(*Context); ok && c != nil {
+			cp := *c
+			scrubbed = &cp
+			scrubRequestContext(&scrubbed.Request, s, &report)
+			t.SignalContext = newContext(scrubbed)
+		}
+	}
+
+	if len(t.Data) > 0 {
+		data := make([]*api.Signal, len(t.Data))
+		for i, sig := range t.Data {
+			if sig == nil || sig.SignalPayload == nil {
+				data[i] = sig
+				continue
+			}
+			cp := *sig
+			path := "data[" + strconv.Itoa(i) + "].payload"
+			cp.SignalPayload = api.NewPayload(sig.SignalPayload.Schema, s.Value(path, sig.SignalPayload.Payload, &report))
+			data[i] = &cp
+		}
+		t.Data = data
+	}
+
+	if scrubbed != nil {
+		scrubbed.Redacted = report.Fields
+	}
+}
+
+func scrubRequestContext(c *RequestContext, s *scrub.Scrubber, r *scrub.Report) {
+	const prefix = "context.request."
+	c.Headers = s.Pairs(prefix+"headers", c.Headers, r)
+	c.Referer = s.URL(prefix+"referer", c.Referer, r)
+	c.Path = s.String(prefix+"path", c.Path, r)
+	c.Route = s.String(prefix+"route", c.Route, r)
+
+	switch params := c.Parameters.(type) {
+	case nil:
+	case *Parameters:
+		c.Parameters = scrubParameters(prefix+"parameters", params, s, r)
+	default:
+		c.Parameters = s.Value(prefix+"parameters", params, r)
+	}
+}
+
+func scrubParameters(path string, p *Parameters, s *scrub.Scrubber, r *scrub.Report) *Parameters {
+	if p == nil {
+		return nil
+	}
+	scrubbed := *p
+	if p.Query != nil {
+		scrubbed.Query = s.Value(path+".query", p.Query, r).(map[string][]string)
+	}
+	if p.Form != nil {
+		scrubbed.Form = s.Value(path+".form", p.Form, r).(map[string][]string)
+	}
+	if p.Path != nil {
+		scrubbed.Path = s.Value(path+".path", p.Path, r).(map[string]string)
+	}
+	scrubbed.JSON = s.Value(path+".json", p.JSON, r)
+	scrubbed.XML = s.Value(path+".xml", p.XML, r)
+	return &scrubbed
+}
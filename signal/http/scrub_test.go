@@ -0,0 +1,52 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/scrub"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScrubTrace(t *testing.T) {
+	params := &sqhttp.Parameters{
+		Query: map[string][]string{"password": {"1234"}, "q": {"search"}},
+		JSON:  map[string]interface{}{"user": map[string]interface{}{"email": "bob@example.com"}},
+	}
+	req := sqhttp.NewRequestContext(time.Now(), time.Now(), "", [][]string{{"Cookie", "session=abc"}, {"Accept", "*/*"}}, "", "https", "POST", "example.com", "", "/users/bob@example.com", "https://example.com/login?token=abc&next=/", 443, 0, params)
+	resp := sqhttp.NewResponseContext(200, "", 0)
+	point := api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, api.NewPayload("my schema", map[string]interface{}{"secret": "s"}))
+	tr := sqhttp.NewTrace("test", time.Now(), nil, nil, sqhttp.NewContext(req, resp), []*api.Signal{(*api.Signal)(point)})
+	original := *tr.Context.(*sqhttp.Context)
+
+	sqhttp.ScrubTrace(tr, scrub.NewDefaultScrubber())
+
+	ctx := tr.Context.(*sqhttp.Context)
+	require.Equal(t, [][]string{{"Cookie", scrub.Redacted}, {"Accept", "*/*"}}, ctx.Request.Headers)
+	require.Equal(t, "/users/"+scrub.Redacted, ctx.Request.Path)
+	require.Equal(t, "https://example.com/login?next=%2F&token=%5Bredacted%5D", ctx.Request.Referer)
+	require.Equal(t, &sqhttp.Parameters{
+		Query: map[string][]string{"password": {scrub.Redacted}, "q": {"search"}},
+		JSON:  map[string]interface{}{"user": map[string]interface{}{"email": scrub.Redacted}},
+	}, ctx.Request.Parameters)
+	require.Equal(t, map[string]interface{}{"secret": scrub.Redacted}, tr.Data[0].SignalPayload.Payload)
+	require.Equal(t, []string{
+		"context.request.headers.Cookie",
+		"context.request.referer.token",
+		"context.request.path",
+		"context.request.parameters.query.password",
+		"context.request.parameters.json.user.email",
+		"data[0].payload.secret",
+	}, ctx.Redacted)
+
+	// The original values are left untouched
+	require.Equal(t, "1234", params.Query["password"][0])
+	require.Equal(t, "session=abc", original.Request.Headers[0][1])
+	require.Equal(t, "s", point.SignalPayload.Payload.(map[string]interface{})["secret"])
+}
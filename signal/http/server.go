@@ -0,0 +1,181 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/internal/responsewriter"
+	"github.com/sqreen/go-sdk/signal/scrub"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+// Handler is a net/http middleware building the HTTP trace of every request it
+// serves. The request context given to the next handler stores the trace
+// collector so that signals can be attached to the trace (cf. package trace).
+// It also stores the span context of the trace, continuing the distributed
+// trace of the traceparent request header when present.
+type Handler struct {
+	// Source is the signal source of the traces.
+	Source string
+	// Metrics are the server metrics recorded for every request. They are not
+	// recorded when nil.
+	Metrics *ServerMetrics
+	// MaxSignals is the maximum number of signals per trace.
+	// trace.DefaultMaxSignals is used when zero.
+	MaxSignals int
+	// Budget limits the total number of signals and bytes buffered by the
+	// in-flight requests, including their parameters. The default budget of
+	// the trace package is used when nil (cf. trace.NewCollector()).
+	Budget *trace.Budget
+	// RouteResolver resolves the route template of the requests.
+	// DefaultRouteResolver is used when nil, and NormalizePath() is used when
+	// the route couldn't be resolved.
+	RouteResolver RouteResolver
+	// Parameters are the limits of the extraction of the request parameters
+	// into the request context (cf. ExtractParameters()). Parameters are not
+	// extracted when nil.
+	Parameters *ParametersConfig
+	// Headers is the policy of the capture of the request headers.
+	// DefaultHeaderPolicy is used when nil.
+	Headers *HeaderPolicy
+	// Sampler decides whether the trace of a request is exported, such as a
+	// RetentionPolicy. Every trace is exported when nil. Server metrics are
+	// recorded regardless of the sampling decision.
+	Sampler Sampler
+	// Scrubber scrubs the traces before they are exported (cf. ScrubTrace()).
+	// Traces are not scrubbed when nil.
+	Scrubber *scrub.Scrubber
+
+	next     http.Handler
+	exporter client.Exporter
+}
+
+// NewHandler returns a new middleware handler serving the requests with next
+// and exporting their traces with the given exporter.
+func NewHandler(next http.Handler, exporter client.Exporter) *Handler {
+	return &Handler{
+		next:     next,
+		exporter: exporter,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	c := trace.NewCollector(h.MaxSignals, h.Budget)
+	defer c.Release()
+	rw := responsewriter.New(w)
+	sc := trace.NewSpanContext()
+	if remote, ok := trace.ParseTraceparent(r.Header.Get(trace.TraceparentHeader)); ok {
+		sc = remote.Child()
+	}
+	r = r.WithContext(trace.WithSpanContext(trace.NewContext(r.Context(), c), sc))
+	var params *Parameters
+	if h.Parameters != nil {
+		params = ExtractParameters(r, *h.Parameters)
+		// The parameters are buffered until the end of the request
+		if !c.Reserve(jsonSize(params)) {
+			params = nil
+		}
+	}
+
+	// The trace is also exported when the next handler panics, such as the
+	// recovery middleware of package exception re-panicking.
+	defer func() {
+		v := recover()
+		h.export(r, rw, c, sc, params, start, v != nil)
+		if v != nil {
+			panic(v)
+		}
+	}()
+	h.next.ServeHTTP(rw.Wrap(), r)
+}
+
+// export exports the trace of the request served with rw since start. The
+// response status is 500 when the request panicked before writing it.
+func (h *Handler) export(r *http.Request, rw *responsewriter.Writer, c *trace.Collector, sc trace.SpanContext, params *Parameters, start time.Time, panicked bool) {
+	end := time.Now()
+	reqCtx := NewRequestContextFromRequest(r, start, end, h.Headers)
+	reqCtx.Route = h.route(r)
+	if params != nil {
+		params.Path = PathParameters(r)
+		reqCtx.Parameters = params
+	}
+	status := rw.Status()
+	if panicked && !rw.Committed() {
+		status = http.StatusInternalServerError
+	}
+	respCtx := NewResponseContext(status, rw.Header().Get("Content-Type"), rw.Written())
+	actor := NewActor([]string{reqCtx.RemoteIP}, reqCtx.UserAgent, c.Identifiers())
+	traceCtx := NewContext(reqCtx, respCtx)
+	tr := NewTrace(h.Source, start, actor, nil, traceCtx, c.Signals())
+	tr.TraceID, tr.SpanID, tr.ParentID = sc.TraceID, sc.SpanID, sc.ParentID
+
+	if h.Metrics != nil {
+		h.Metrics.record(reqCtx, respCtx)
+	}
+
+	if h.Sampler != nil {
+		decision := h.Sampler.Sample(tr)
+		if !decision.Sampled {
+			return
+		}
+		traceCtx.Sampling = &decision
+	}
+	if h.Scrubber != nil {
+		ScrubTrace(tr, h.Scrubber)
+	}
+	h.exporter.Export(tr)
+}
+
+func (h *Handler) route(r *http.Request) string {
+	resolver := h.RouteResolver
+	if resolver == nil {
+		resolver = DefaultRouteResolver
+	}
+	if route, ok := resolver.Route(r); ok {
+		return route
+	}
+	return NormalizePath(r.URL.Path)
+}
+
+// NewRequestContextFromRequest returns the request context of the given HTTP
+// request processed between start and end. Request headers are captured
+// according to the given header policy, or DefaultHeaderPolicy when nil.
+func NewRequestContextFromRequest(r *http.Request, start, end time.Time, policy *HeaderPolicy) *RequestContext {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host, port := splitHostPort(r.Host)
+	if port == 0 {
+		port = 80
+		if r.TLS != nil {
+			port = 443
+		}
+	}
+	remoteIP, remotePort := splitHostPort(r.RemoteAddr)
+
+	if policy == nil {
+		policy = DefaultHeaderPolicy
+	}
+	headers := policy.Capture(r.Header)
+
+	return NewRequestContext(start, end, r.Header.Get("X-Request-Id"), headers, r.UserAgent(), scheme, r.Method, host, remoteIP, r.URL.Path, r.Referer(), port, remotePort, nil)
+}
+
+func splitHostPort(addr string) (host string, port uint64) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		// No port in the address
+		return addr, 0
+	}
+	port, _ = strconv.ParseUint(portStr, 10, 16)
+	return host, port
+}
@@ -0,0 +1,249 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/exception"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/metrics"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	t.Run("trace", func(t *testing.T) {
+		var exporter testutil.Exporter
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			ok := trace.AddPoint(r.Context(), api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+			require.True(t, ok)
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("hello"))
+		})
+		h := sqhttp.NewHandler(mux, &exporter)
+		h.Source = "test"
+
+		req := httptest.NewRequest("GET", "http://example.com:8080/users/123?a=b", nil)
+		req.Header.Set("User-Agent", "my ua")
+		req.Header.Set("X-Request-Id", "my rid")
+		req.RemoteAddr = "1.2.3.4:5678"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusCreated, rec.Code)
+
+		exported := exportedTraces(&exporter)
+		require.Len(t, exported, 1)
+		tr := exported[0]
+		require.Equal(t, "test", tr.Source)
+		require.Len(t, tr.Data, 1)
+		require.Equal(t, "my point", tr.Data[0].Name)
+		require.Equal(t, &sqhttp.Actor{IPAddresses: []string{"1.2.3.4"}, UserAgent: "my ua"}, tr.Actor)
+
+		ctx := tr.Context.(*sqhttp.Context)
+		require.Equal(t, "GET", ctx.Request.Verb)
+		require.Equal(t, "http", ctx.Request.Scheme)
+		require.Equal(t, "example.com", ctx.Request.Host)
+		require.Equal(t, uint64(8080), ctx.Request.Port)
+		require.Equal(t, "1.2.3.4", ctx.Request.RemoteIP)
+		require.Equal(t, uint64(5678), ctx.Request.RemotePort)
+		require.Equal(t, "/users/123", ctx.Request.Path)
+		require.Equal(t, "my rid", ctx.Request.Rid)
+		require.Equal(t, "my ua", ctx.Request.UserAgent)
+		require.Equal(t, sqhttp.ResponseContext{Status: http.StatusCreated, ContentType: "text/plain", ContentLength: 5}, ctx.Response)
+	})
+
+	t.Run("trace identifiers", func(t *testing.T) {
+		var exporter testutil.Exporter
+		var outbound string
+		backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			outbound = r.Header.Get(trace.TraceparentHeader)
+		}))
+		defer backend.Close()
+		client := &http.Client{Transport: sqhttp.NewTransport(nil)}
+		h := sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := http.NewRequestWithContext(r.Context(), "GET", backend.URL, nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+		}), &exporter)
+
+		t.Run("new trace", func(t *testing.T) {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			tr := exportedTraces(&exporter)[0]
+			require.Len(t, tr.TraceID, 32)
+			require.Len(t, tr.SpanID, 16)
+			require.Empty(t, tr.ParentID)
+			require.Equal(t, "00-"+tr.TraceID+"-"+tr.SpanID+"-01", outbound)
+		})
+
+		t.Run("incoming trace", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			tr := exportedTraces(&exporter)[1]
+			require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tr.TraceID)
+			require.Equal(t, "00f067aa0ba902b7", tr.ParentID)
+			require.Len(t, tr.SpanID, 16)
+			require.NotEqual(t, tr.ParentID, tr.SpanID)
+			require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+tr.SpanID+"-00", outbound)
+		})
+	})
+
+	t.Run("server metrics", func(t *testing.T) {
+		var exporter testutil.Exporter
+		store := metrics.NewStore("test", time.Minute)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		})
+		h := sqhttp.NewHandler(mux, &exporter)
+		h.Metrics = sqhttp.NewServerMetrics(store)
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest("GET", "/users/1", nil),
+			httptest.NewRequest("GET", "/users/2", nil),
+			httptest.NewRequest("POST", "/users/3", nil),
+			httptest.NewRequest("GET", "/not-found", nil),
+		} {
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		flushed := map[string]*api.Metric{}
+		for _, m := range store.Flush(time.Now()) {
+			flushed[m.Name] = m
+		}
+		require.Len(t, flushed, 5)
+		require.Equal(t, map[string]int64{"2xx": 2, "5xx": 1, "4xx": 1}, sumValues(t, flushed[sqhttp.StatusClassesMetricName]))
+		require.Equal(t, map[string]int64{"GET": 3, "POST": 1}, sumValues(t, flushed[sqhttp.VerbsMetricName]))
+		require.Equal(t, map[string]int64{"/users/{id}": 3, "/not-found": 1}, sumValues(t, flushed[sqhttp.RoutesMetricName]))
+
+		latency := flushed[sqhttp.LatencyMetricName].Payload.(api.BinningMetricsSignalPayload)
+		require.Equal(t, int64(4), sumBins(latency.Bins))
+		size := flushed[sqhttp.ResponseSizeMetricName].Payload.(api.BinningMetricsSignalPayload)
+		require.Equal(t, int64(4), sumBins(size.Bins))
+	})
+
+	t.Run("repanic", func(t *testing.T) {
+		var exporter testutil.Exporter
+		recovery := exception.NewHandler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { panic("oops") }))
+		recovery.Repanic = true
+		h := sqhttp.NewHandler(recovery, &exporter)
+		req := httptest.NewRequest("GET", "/", nil)
+		require.PanicsWithValue(t, "oops", func() {
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		})
+
+		exported := exportedTraces(&exporter)
+		require.Len(t, exported, 1)
+		require.Len(t, exported[0].Data, 1)
+		require.Equal(t, exception.PointName, exported[0].Data[0].Name)
+		require.Equal(t, http.StatusInternalServerError, exported[0].Context.(*sqhttp.Context).Response.Status)
+	})
+
+	t.Run("optional response writer interfaces", func(t *testing.T) {
+		for _, tc := range []struct {
+			name     string
+			w        http.ResponseWriter
+			flusher  bool
+			hijacker bool
+		}{
+			{name: "recorder", w: httptest.NewRecorder(), flusher: true},
+			{name: "hijacker", w: hijackerRecorder{httptest.NewRecorder()}, hijacker: true},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				var exporter testutil.Exporter
+				h := sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, isFlusher := w.(http.Flusher)
+					require.Equal(t, tc.flusher, isFlusher)
+					_, isHijacker := w.(http.Hijacker)
+					require.Equal(t, tc.hijacker, isHijacker)
+					_, isPusher := w.(http.Pusher)
+					require.False(t, isPusher)
+					_, isReaderFrom := w.(io.ReaderFrom)
+					require.False(t, isReaderFrom)
+				}), &exporter)
+				h.ServeHTTP(tc.w, httptest.NewRequest("GET", "/", nil))
+			})
+		}
+
+		t.Run("hijacked connections", func(t *testing.T) {
+			var exporter testutil.Exporter
+			srv := httptest.NewServer(sqhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, buf, err := http.NewResponseController(w).Hijack()
+				require.NoError(t, err)
+				defer conn.Close()
+				_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+				_ = buf.Flush()
+			}), &exporter))
+			defer srv.Close()
+
+			req, err := http.NewRequest("GET", srv.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "test")
+			res, err := srv.Client().Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+		})
+	})
+}
+
+// hijackerRecorder is a response recorder only implementing the
+// http.Hijacker optional interface.
+type hijackerRecorder struct {
+	rec *httptest.ResponseRecorder
+}
+
+func (r hijackerRecorder) Header() http.Header         { return r.rec.Header() }
+func (r hijackerRecorder) Write(b []byte) (int, error) { return r.rec.Write(b) }
+func (r hijackerRecorder) WriteHeader(status int)      { r.rec.WriteHeader(status) }
+func (hijackerRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+// exportedTraces returns the HTTP traces exported so far.
+func exportedTraces(e *testutil.Exporter) []*sqhttp.Trace {
+	var traces []*sqhttp.Trace
+	for _, s := range e.Signals() {
+		if tr, ok := s.(*sqhttp.Trace); ok {
+			traces = append(traces, tr)
+		}
+	}
+	return traces
+}
+
+func sumValues(t *testing.T, m *api.Metric) map[string]int64 {
+	require.NotNil(t, m)
+	payload, ok := m.Payload.(api.MetricSignalPayload)
+	require.True(t, ok)
+	values := make(map[string]int64, len(payload.Values))
+	for _, e := range payload.Values {
+		values[e.Key] = e.Value
+	}
+	return values
+}
+
+func sumBins(bins map[string]int64) (n int64) {
+	for _, v := range bins {
+		n += v
+	}
+	return n
+}
@@ -0,0 +1,399 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+const (
+	// EgressPointName is the name of outbound request points.
+	EgressPointName = "http_egress"
+	// EgressPayloadSchema is the payload schema of outbound request points.
+	EgressPayloadSchema = "http_egress/2020-01-01T00:00:00.000Z"
+)
+
+// EgressPayload is the payload of outbound request points.
+type EgressPayload struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Port   uint64 `json:"port"`
+	// IP is the address the request was sent to, or the denied address of a
+	// blocked request.
+	IP string `json:"ip,omitempty"`
+	// Status is the response status code, zero when no response was received.
+	Status int `json:"status,omitempty"`
+	// Duration is the round trip duration in milliseconds.
+	Duration float64 `json:"duration_ms"`
+	// Private, LinkLocal and Metadata flag requests to private, link-local
+	// and cloud metadata addresses.
+	Private   bool `json:"private,omitempty"`
+	LinkLocal bool `json:"link_local,omitempty"`
+	Metadata  bool `json:"metadata,omitempty"`
+	// Blocked is true when the request was blocked by the deny rule Rule.
+	Blocked bool   `json:"blocked,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+var (
+	// PrivateNetworks are the networks of the private addresses (cf.
+	// IsPrivateAddr()).
+	PrivateNetworks = []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+		netip.MustParsePrefix("192.168.0.0/16"),
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("fc00::/7"),
+		netip.MustParsePrefix("::1/128"),
+		netip.MustParsePrefix("::/128"),
+	}
+	// LinkLocalNetworks are the link-local networks.
+	LinkLocalNetworks = []netip.Prefix{
+		netip.MustParsePrefix("169.254.0.0/16"),
+		netip.MustParsePrefix("fe80::/10"),
+	}
+	// MetadataNetworks are the addresses of the cloud metadata services.
+	MetadataNetworks = []netip.Prefix{
+		netip.MustParsePrefix("169.254.169.254/32"),
+		netip.MustParsePrefix("169.254.170.2/32"),
+		netip.MustParsePrefix("100.100.100.200/32"),
+		netip.MustParsePrefix("fd00:ec2::254/128"),
+	}
+	// MetadataHosts are the host names of the cloud metadata services.
+	MetadataHosts = []string{"metadata.google.internal", "metadata.azure.com"}
+
+	// sharedNetwork is the shared address space of carrier-grade NATs.
+	sharedNetwork = netip.MustParsePrefix("100.64.0.0/10")
+	// thisNetwork is the IPv4 "this network" block, 0.0.0.0 being routed to
+	// the local host.
+	thisNetwork = netip.MustParsePrefix("0.0.0.0/8")
+)
+
+// IsPrivateAddr returns true when the address is a loopback, private, shared
+// (100.64.0.0/10), unspecified or "this network" (0.0.0.0/8) address,
+// including in its IPv4-mapped IPv6 form.
+func IsPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || sharedNetwork.Contains(addr) || thisNetwork.Contains(addr)
+}
+
+// IsLinkLocalAddr returns true when the address is a link-local unicast
+// address, including in its IPv4-mapped IPv6 form.
+func IsLinkLocalAddr(addr netip.Addr) bool {
+	return addr.Unmap().IsLinkLocalUnicast()
+}
+
+// EgressRule is a deny rule of outbound requests. A request matches the rule
+// when its host matches one of the host patterns, or when one of its addresses
+// belongs to one of the networks.
+type EgressRule struct {
+	// Name is the rule name reported in the points of blocked requests.
+	Name string
+	// Hosts are host names, or host name suffixes when they start with "*."
+	// such as "*.internal". Host names are case-insensitive.
+	Hosts []string
+	// Networks are the denied networks.
+	Networks []netip.Prefix
+}
+
+// DenyMetadataRule denies requests to the cloud metadata services.
+var DenyMetadataRule = EgressRule{
+	Name:     "metadata",
+	Hosts:    MetadataHosts,
+	Networks: MetadataNetworks,
+}
+
+func (r *EgressRule) matchHost(host string) bool {
+	// Fully qualified host names end with a dot
+	host = strings.TrimSuffix(host, ".")
+	for _, h := range r.Hosts {
+		if strings.HasPrefix(h, "*.") {
+			if len(host) > len(h)-1 && strings.EqualFold(host[len(host)-len(h)+1:], h[1:]) {
+				return true
+			}
+		} else if strings.EqualFold(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// EgressDeniedError is the error returned by the transport when a request is
+// blocked by a deny rule.
+type EgressDeniedError struct {
+	Host string
+	Rule string
+	// IP is the denied address, empty when the host name was denied.
+	IP string
+}
+
+func (e *EgressDeniedError) Error() string {
+	return fmt.Sprintf("outbound request to %s denied by rule %q", e.Host, e.Rule)
+}
+
+// Transport is an http.RoundTripper wrapper recording outbound requests as
+// points attached to the trace of the request context (cf. package trace).
+// Requests matching a deny rule are blocked and reported. The span context of
+// the request context is propagated in the traceparent header of the requests
+// not already having one.
+//
+// The deny rules with networks are evaluated when connecting, on the addresses
+// actually dialed, so that host names resolving to other addresses when
+// connecting (aka. DNS rebinding) are also blocked. The addresses of proxies
+// are therefore evaluated instead of the request ones. When the base round
+// tripper is not an *http.Transport dialing the connections itself, host
+// names are resolved before the request is sent instead, and requests whose
+// host name cannot be resolved fail.
+type Transport struct {
+	// Source is the signal source of the points.
+	Source string
+	// Deny are the deny rules of outbound requests.
+	Deny []EgressRule
+	// Resolver resolves the host names of outbound requests when deny rules
+	// have networks. net.DefaultResolver is used when nil.
+	Resolver *net.Resolver
+
+	base http.RoundTripper
+	// dialChecked is true when the base transport dials the connections with
+	// dialContext().
+	dialChecked bool
+}
+
+// NewTransport returns a new transport sending the requests with base.
+// http.DefaultTransport is used when base is nil. When base is an
+// *http.Transport, the requests are sent with a clone of it whose connections
+// are checked against the deny rules.
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &Transport{base: base}
+	if tr, ok := base.(*http.Transport); ok && tr.DialTLSContext == nil && tr.DialTLS == nil {
+		tr = tr.Clone()
+		dial := tr.DialContext
+		if dial == nil && tr.Dial != nil {
+			dial = func(_ context.Context, network, address string) (net.Conn, error) {
+				return tr.Dial(network, address)
+			}
+		}
+		if dial == nil {
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		tr.DialContext, tr.Dial = t.dialContext(dial), nil
+		t.base, t.dialChecked = tr, true
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := r.Context()
+	host := strings.TrimSuffix(r.URL.Hostname(), ".")
+	payload := &EgressPayload{
+		Method: r.Method,
+		Host:   host,
+		Port:   urlPort(r),
+	}
+
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr.Unmap()}
+	}
+	rule, addr := t.deny(host, addrs)
+	if rule == nil && addrs == nil && !t.dialChecked && t.hasNetworks() {
+		var err error
+		if addrs, err = t.lookup(ctx, host); err != nil {
+			// Fail closed when the deny rules cannot be evaluated
+			payload.Error = err.Error()
+			t.record(ctx, start, payload)
+			return nil, err
+		}
+		rule, addr = t.deny("", addrs)
+	}
+	if rule != nil {
+		err := &EgressDeniedError{Host: host, Rule: rule.Name}
+		if addr.IsValid() {
+			err.IP = addr.String()
+		}
+		payload.IP = err.IP
+		classify(payload, host, addrs)
+		payload.Blocked, payload.Rule, payload.Error = true, rule.Name, err.Error()
+		t.record(ctx, start, payload)
+		return nil, err
+	}
+
+	if sc, ok := trace.SpanContextFromContext(ctx); ok && r.Header.Get(trace.TraceparentHeader) == "" {
+		r = r.Clone(ctx)
+		r.Header.Set(trace.TraceparentHeader, sc.Traceparent())
+	}
+	if trace.FromContext(ctx) != nil {
+		r = r.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				if ap, err := netip.ParseAddrPort(info.Conn.RemoteAddr().String()); err == nil {
+					payload.IP = ap.Addr().Unmap().String()
+				}
+			},
+		}))
+	}
+	resp, err := t.base.RoundTrip(r)
+
+	var denied *EgressDeniedError
+	if errors.As(err, &denied) {
+		payload.Blocked, payload.Rule = true, denied.Rule
+		payload.IP = denied.IP
+	}
+	if addr, err := netip.ParseAddr(payload.IP); err == nil {
+		addrs = []netip.Addr{addr}
+	}
+	classify(payload, host, addrs)
+	if err != nil {
+		payload.Error = err.Error()
+	} else {
+		payload.Status = resp.StatusCode
+	}
+	t.record(ctx, start, payload)
+	return resp, err
+}
+
+// dialContext returns the dial function denying the connections to the
+// networks of the deny rules. Host names are resolved and their addresses
+// dialed with dial, so that the evaluated addresses are the dialed ones.
+func (t *Transport) dialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if !t.hasNetworks() {
+			return dial(ctx, network, address)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		host = strings.TrimSuffix(host, ".")
+		addrs, err := t.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		if rule, addr := t.deny("", addrs); rule != nil {
+			return nil, &EgressDeniedError{Host: host, Rule: rule.Name, IP: addr.String()}
+		}
+		var firstErr error
+		for _, addr := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(addr.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		return nil, firstErr
+	}
+}
+
+// hasNetworks returns true when some deny rules have networks.
+func (t *Transport) hasNetworks() bool {
+	for i := range t.Deny {
+		if len(t.Deny[i].Networks) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup returns the addresses of the host.
+func (t *Transport) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+	resolver := t.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+	return addrs, nil
+}
+
+// deny returns the first deny rule matching the host or one of the addresses,
+// along with the matching address.
+func (t *Transport) deny(host string, addrs []netip.Addr) (*EgressRule, netip.Addr) {
+	for i := range t.Deny {
+		rule := &t.Deny[i]
+		if host != "" && rule.matchHost(host) {
+			return rule, netip.Addr{}
+		}
+		for _, addr := range addrs {
+			if containsAddr(rule.Networks, addr) {
+				return rule, addr
+			}
+		}
+	}
+	return nil, netip.Addr{}
+}
+
+func (t *Transport) record(ctx context.Context, start time.Time, payload *EgressPayload) {
+	if trace.FromContext(ctx) == nil {
+		return
+	}
+	payload.Duration = float64(time.Since(start)) / float64(time.Millisecond)
+	trace.AddPoint(ctx, api.NewPoint(EgressPointName, t.Source, start, nil, nil, nil, nil, nil, api.NewPayload(EgressPayloadSchema, payload)))
+}
+
+// classify sets the address class flags of the payload.
+func classify(payload *EgressPayload, host string, addrs []netip.Addr) {
+	for _, h := range MetadataHosts {
+		if strings.EqualFold(strings.TrimSuffix(host, "."), h) {
+			payload.Metadata = true
+		}
+	}
+	for _, addr := range addrs {
+		payload.Private = payload.Private || IsPrivateAddr(addr)
+		payload.LinkLocal = payload.LinkLocal || IsLinkLocalAddr(addr)
+		payload.Metadata = payload.Metadata || containsAddr(MetadataNetworks, addr)
+	}
+}
+
+func containsAddr(networks []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, n := range networks {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func urlPort(r *http.Request) uint64 {
+	if port, err := strconv.ParseUint(r.URL.Port(), 10, 16); err == nil {
+		return port
+	}
+	if r.URL.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
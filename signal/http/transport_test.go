@@ -0,0 +1,206 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package http_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	do := func(t *testing.T, transport *sqhttp.Transport, url string) (*sqhttp.EgressPayload, error) {
+		c := trace.NewCollector(0, nil)
+		req, err := http.NewRequestWithContext(trace.NewContext(context.Background(), c), http.MethodGet, url, nil)
+		require.NoError(t, err)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		signals := c.Signals()
+		require.Len(t, signals, 1)
+		require.Equal(t, sqhttp.EgressPointName, signals[0].Name)
+		require.Equal(t, "test", signals[0].Source)
+		return signals[0].SignalPayload.Payload.(*sqhttp.EgressPayload), err
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		transport := sqhttp.NewTransport(nil)
+		transport.Source = "test"
+		transport.Deny = []sqhttp.EgressRule{sqhttp.DenyMetadataRule}
+
+		p, err := do(t, transport, srv.URL+"/path?q=1")
+		require.NoError(t, err)
+		require.Equal(t, http.MethodGet, p.Method)
+		require.Equal(t, "127.0.0.1", p.Host)
+		require.Equal(t, "127.0.0.1", p.IP)
+		_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		require.Equal(t, port, strconv.FormatUint(p.Port, 10))
+		require.Equal(t, http.StatusTeapot, p.Status)
+		require.True(t, p.Private)
+		require.False(t, p.LinkLocal)
+		require.False(t, p.Metadata)
+		require.False(t, p.Blocked)
+		require.Empty(t, p.Error)
+	})
+
+	t.Run("without trace", func(t *testing.T) {
+		transport := sqhttp.NewTransport(nil)
+		resp, err := transport.RoundTrip(httptest.NewRequest(http.MethodGet, srv.URL, nil))
+		require.NoError(t, err)
+		resp.Body.Close()
+	})
+
+	for _, tc := range []struct {
+		name     string
+		url      string
+		rule     sqhttp.EgressRule
+		metadata bool
+	}{
+		{
+			name:     "metadata address",
+			url:      "http://169.254.169.254/latest/meta-data/",
+			rule:     sqhttp.DenyMetadataRule,
+			metadata: true,
+		},
+		{
+			name:     "IPv4-mapped metadata address",
+			url:      "http://[::ffff:169.254.169.254]/latest/meta-data/",
+			rule:     sqhttp.DenyMetadataRule,
+			metadata: true,
+		},
+		{
+			name:     "metadata host",
+			url:      "http://Metadata.Google.Internal/computeMetadata/v1/",
+			rule:     sqhttp.DenyMetadataRule,
+			metadata: true,
+		},
+		{
+			name:     "fully qualified metadata host",
+			url:      "http://metadata.google.internal./computeMetadata/v1/",
+			rule:     sqhttp.DenyMetadataRule,
+			metadata: true,
+		},
+		{
+			name: "host suffix",
+			url:  "https://api.corp.internal:8443/",
+			rule: sqhttp.EgressRule{Name: "internal", Hosts: []string{"*.internal"}},
+		},
+		{
+			name: "network",
+			url:  srv.URL,
+			rule: sqhttp.EgressRule{Name: "private", Networks: sqhttp.PrivateNetworks},
+		},
+	} {
+		tc := tc
+		t.Run("denied "+tc.name, func(t *testing.T) {
+			transport := sqhttp.NewTransport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+				t.Fatal("unexpected request")
+				return nil, nil
+			}))
+			transport.Source = "test"
+			transport.Deny = []sqhttp.EgressRule{tc.rule}
+
+			p, err := do(t, transport, tc.url)
+			var denied *sqhttp.EgressDeniedError
+			require.True(t, errors.As(err, &denied))
+			require.Equal(t, tc.rule.Name, denied.Rule)
+			require.True(t, p.Blocked)
+			require.Equal(t, tc.rule.Name, p.Rule)
+			require.Equal(t, err.Error(), p.Error)
+			require.Equal(t, tc.metadata, p.Metadata)
+			require.Zero(t, p.Status)
+		})
+	}
+
+	t.Run("denied when connecting", func(t *testing.T) {
+		transport := sqhttp.NewTransport(nil)
+		transport.Source = "test"
+		transport.Deny = []sqhttp.EgressRule{{Name: "private", Networks: sqhttp.PrivateNetworks}}
+
+		// The host name is resolved when connecting
+		_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+		p, err := do(t, transport, "http://localhost:"+port+"/")
+		var denied *sqhttp.EgressDeniedError
+		require.True(t, errors.As(err, &denied))
+		require.Equal(t, "private", denied.Rule)
+		require.Equal(t, "localhost", denied.Host)
+		require.True(t, p.Blocked)
+		require.Equal(t, "private", p.Rule)
+		require.Equal(t, denied.IP, p.IP)
+		require.True(t, p.Private)
+		require.Zero(t, p.Status)
+	})
+
+	t.Run("failed lookup", func(t *testing.T) {
+		transport := sqhttp.NewTransport(roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			t.Fatal("unexpected request")
+			return nil, nil
+		}))
+		transport.Source = "test"
+		transport.Deny = []sqhttp.EgressRule{{Name: "private", Networks: sqhttp.PrivateNetworks}}
+		transport.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(context.Context, string, string) (net.Conn, error) {
+				return nil, errors.New("no dns")
+			},
+		}
+
+		p, err := do(t, transport, "http://does-not-exist.invalid/")
+		require.Error(t, err)
+		require.False(t, p.Blocked)
+		require.Equal(t, err.Error(), p.Error)
+	})
+}
+
+func TestAddrClasses(t *testing.T) {
+	for _, tc := range []struct {
+		addr      string
+		private   bool
+		linkLocal bool
+	}{
+		{addr: "10.1.2.3", private: true},
+		{addr: "127.0.0.1", private: true},
+		{addr: "100.64.1.2", private: true},
+		{addr: "0.0.0.0", private: true},
+		{addr: "0.1.2.3", private: true},
+		{addr: "::", private: true},
+		{addr: "::1", private: true},
+		{addr: "fd00::1", private: true},
+		{addr: "::ffff:10.1.2.3", private: true},
+		{addr: "::ffff:127.0.0.1", private: true},
+		{addr: "169.254.1.2", linkLocal: true},
+		{addr: "::ffff:169.254.169.254", linkLocal: true},
+		{addr: "fe80::1", linkLocal: true},
+		{addr: "8.8.8.8"},
+		{addr: "100.128.0.1"},
+		{addr: "2001:db8::1"},
+	} {
+		tc := tc
+		t.Run(tc.addr, func(t *testing.T) {
+			addr := netip.MustParseAddr(tc.addr)
+			require.Equal(t, tc.private, sqhttp.IsPrivateAddr(addr))
+			require.Equal(t, tc.linkLocal, sqhttp.IsLinkLocalAddr(addr))
+		})
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -0,0 +1,187 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package infra detects the infrastructure the process runs on in order to
+// describe it in the location infra field of the signals.
+package infra
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Infra is the infrastructure record of the signals.
+type Infra struct {
+	Hostname    string      `json:"hostname,omitempty"`
+	OS          string      `json:"os"`
+	Arch        string      `json:"arch"`
+	GoVersion   string      `json:"go_version"`
+	SDKVersion  string      `json:"sdk_version,omitempty"`
+	PID         int         `json:"pid"`
+	ContainerID string      `json:"container_id,omitempty"`
+	Kubernetes  *Kubernetes `json:"kubernetes,omitempty"`
+}
+
+// Kubernetes describes the Kubernetes pod the process runs in.
+type Kubernetes struct {
+	PodName   string `json:"pod_name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	NodeName  string `json:"node_name,omitempty"`
+}
+
+// Detector fills the fields of the infrastructure record it can detect.
+type Detector func(*Infra)
+
+// DefaultDetectors are the detectors used by Detect().
+var DefaultDetectors = []Detector{
+	DetectHost,
+	DetectProcess,
+	DetectContainer,
+	DetectKubernetes,
+}
+
+// Detect returns the infrastructure record built by the given detectors, or
+// by DefaultDetectors when none is given.
+func Detect(detectors ...Detector) *Infra {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors
+	}
+	i := &Infra{}
+	for _, detect := range detectors {
+		detect(i)
+	}
+	return i
+}
+
+var (
+	defaultInfra     *Infra
+	defaultInfraOnce sync.Once
+)
+
+// Default returns the infrastructure record built by DefaultDetectors. It is
+// detected once and shared by every caller.
+func Default() *Infra {
+	defaultInfraOnce.Do(func() {
+		defaultInfra = Detect()
+	})
+	return defaultInfra
+}
+
+// sdkModule is the module path of the SDK.
+const sdkModule = "github.com/sqreen/go-sdk/signal"
+
+// DetectHost detects the host name, the OS, the architecture and the Go and
+// SDK versions.
+func DetectHost(i *Infra) {
+	i.Hostname, _ = os.Hostname()
+	i.OS = runtime.GOOS
+	i.Arch = runtime.GOARCH
+	i.GoVersion = runtime.Version()
+	i.SDKVersion = SDKVersion()
+}
+
+// SDKVersion returns the SDK module version read from the build information,
+// or an empty string when unavailable.
+func SDKVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == sdkModule {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == sdkModule {
+			return dep.Version
+		}
+	}
+	return ""
+}
+
+// DetectProcess detects the process ID.
+func DetectProcess(i *Infra) {
+	i.PID = os.Getpid()
+}
+
+// DetectContainer detects the container ID from the cgroups or, with cgroup
+// v2, from the mount points of the process.
+func DetectContainer(i *Infra) {
+	if f, err := os.Open("/proc/self/cgroup"); err == nil {
+		i.ContainerID = ContainerID(f)
+		f.Close()
+	}
+	if i.ContainerID != "" {
+		return
+	}
+	if mountinfo, err := os.ReadFile("/proc/self/mountinfo"); err == nil {
+		if m := mountinfoContainerIDRegexp.FindSubmatch(mountinfo); m != nil {
+			i.ContainerID = string(m[1])
+		}
+	}
+}
+
+// mountinfoContainerIDRegexp matches the files mounted by the container
+// runtimes, such as /etc/hostname, from their container directory.
+var mountinfoContainerIDRegexp = regexp.MustCompile(`/containers/([0-9a-f]{64})/`)
+
+// containerIDRegexp matches the 64-character container IDs of docker,
+// containerd and cri-o, and the task container IDs of AWS ECS.
+var containerIDRegexp = regexp.MustCompile(`(?:^|[/-])([0-9a-f]{64}|[0-9a-f]{32}-[0-9]{9,10})(?:\.scope)?(?:$|[/\s])`)
+
+// ContainerID returns the first container ID found in the lines of r, such as
+// the content of /proc/self/cgroup.
+func ContainerID(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Skip the sandbox containers of the container runtimes
+		if strings.Contains(line, "/sandboxes/") {
+			continue
+		}
+		if m := containerIDRegexp.FindStringSubmatch(line); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// Kubernetes environment variables usually set using the downward API, and
+// file of the pod namespace mounted by the service account.
+const (
+	PodNameEnvVar      = "POD_NAME"
+	PodNamespaceEnvVar = "POD_NAMESPACE"
+	NodeNameEnvVar     = "NODE_NAME"
+	namespaceFile      = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+)
+
+// DetectKubernetes detects the Kubernetes pod name, namespace and node name
+// from the downward API environment variables. When running in Kubernetes
+// without them, the pod name defaults to the host name and the namespace to
+// the one of the service account.
+func DetectKubernetes(i *Infra) {
+	k := &Kubernetes{
+		PodName:   os.Getenv(PodNameEnvVar),
+		Namespace: os.Getenv(PodNamespaceEnvVar),
+		NodeName:  os.Getenv(NodeNameEnvVar),
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		if k.PodName == "" {
+			k.PodName, _ = os.Hostname()
+		}
+		if k.Namespace == "" {
+			if ns, err := os.ReadFile(namespaceFile); err == nil {
+				k.Namespace = strings.TrimSpace(string(ns))
+			}
+		}
+	}
+	if *k != (Kubernetes{}) {
+		i.Kubernetes = k
+	}
+}
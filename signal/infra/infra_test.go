@@ -0,0 +1,100 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package infra_test
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/sqreen/go-sdk/signal/infra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetect(t *testing.T) {
+	i := infra.Detect()
+	hostname, _ := os.Hostname()
+	require.Equal(t, hostname, i.Hostname)
+	require.Equal(t, runtime.GOOS, i.OS)
+	require.Equal(t, runtime.GOARCH, i.Arch)
+	require.Equal(t, runtime.Version(), i.GoVersion)
+	require.Equal(t, os.Getpid(), i.PID)
+
+	require.Same(t, infra.Default(), infra.Default())
+
+	i = infra.Detect(infra.DetectProcess, func(i *infra.Infra) { i.Hostname = "my host" })
+	require.Equal(t, &infra.Infra{PID: os.Getpid(), Hostname: "my host"}, i)
+}
+
+func TestContainerID(t *testing.T) {
+	const id = "3c1b1e0bcd5a87d3ec9f7cd6f70b5ab3d6e6d0f6d1e0a5b0b2f0e5f2a8b6c0d1"
+	for _, tc := range []struct {
+		name, cgroup, expected string
+	}{
+		{
+			name:     "docker",
+			cgroup:   "12:memory:/docker/" + id + "\n11:cpu:/docker/" + id,
+			expected: id,
+		},
+		{
+			name:     "systemd",
+			cgroup:   "1:name=systemd:/system.slice/docker-" + id + ".scope",
+			expected: id,
+		},
+		{
+			name:     "kubernetes",
+			cgroup:   "4:pids:/kubepods/besteffort/pod5b3f0e2c-0c3a-4e4f-9c5e-6f3b1b6a1e2d/" + id,
+			expected: id,
+		},
+		{
+			name:     "ecs",
+			cgroup:   "9:perf_event:/ecs/55091c13b9f34b8f8a5d6b1b0c3f4e2d/55091c13b9f34b8f8a5d6b1b0c3f4e2d-2385455014",
+			expected: "55091c13b9f34b8f8a5d6b1b0c3f4e2d-2385455014",
+		},
+		{
+			name:   "host",
+			cgroup: "0::/user.slice/user-1000.slice/session-2.scope",
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, infra.ContainerID(strings.NewReader(tc.cgroup)))
+		})
+	}
+}
+
+func TestDetectKubernetes(t *testing.T) {
+	t.Run("downward api", func(t *testing.T) {
+		t.Setenv(infra.PodNameEnvVar, "my-pod")
+		t.Setenv(infra.PodNamespaceEnvVar, "my-namespace")
+		t.Setenv(infra.NodeNameEnvVar, "my-node")
+		var i infra.Infra
+		infra.DetectKubernetes(&i)
+		require.Equal(t, &infra.Kubernetes{PodName: "my-pod", Namespace: "my-namespace", NodeName: "my-node"}, i.Kubernetes)
+	})
+
+	t.Run("in cluster", func(t *testing.T) {
+		t.Setenv(infra.PodNameEnvVar, "")
+		t.Setenv(infra.PodNamespaceEnvVar, "")
+		t.Setenv(infra.NodeNameEnvVar, "")
+		t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+		var i infra.Infra
+		infra.DetectKubernetes(&i)
+		hostname, _ := os.Hostname()
+		require.NotNil(t, i.Kubernetes)
+		require.Equal(t, hostname, i.Kubernetes.PodName)
+	})
+
+	t.Run("not in kubernetes", func(t *testing.T) {
+		t.Setenv(infra.PodNameEnvVar, "")
+		t.Setenv(infra.PodNamespaceEnvVar, "")
+		t.Setenv(infra.NodeNameEnvVar, "")
+		t.Setenv("KUBERNETES_SERVICE_HOST", "")
+		var i infra.Infra
+		infra.DetectKubernetes(&i)
+		require.Nil(t, i.Kubernetes)
+	})
+}
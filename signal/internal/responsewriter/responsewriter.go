@@ -0,0 +1,236 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package responsewriter provides the HTTP response writer wrapper used by the
+// middlewares to observe the responses of the next handlers.
+package responsewriter
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// Writer wraps a response writer in order to know the response status code
+// and length.
+type Writer struct {
+	http.ResponseWriter
+	status   int
+	written  int64
+	hijacked bool
+}
+
+// New returns a new wrapper of the response writer.
+func New(w http.ResponseWriter) *Writer {
+	return &Writer{ResponseWriter: w}
+}
+
+func (w *Writer) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+// Status returns the response status code, http.StatusOK when it wasn't
+// written.
+func (w *Writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written returns the number of bytes of the response body written so far.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
+// Committed returns true when the response header was written, or when the
+// connection was hijacked.
+func (w *Writer) Committed() bool {
+	return w.status != 0 || w.hijacked
+}
+
+// Unwrap allows http.ResponseController to access the wrapped response writer.
+func (w *Writer) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *Writer) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *Writer) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *Writer) readFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	w.written += n
+	return n, err
+}
+
+// Optional interfaces of the wrapped response writer.
+const (
+	flusher = 1 << iota
+	hijacker
+	readerFrom
+	pusher
+)
+
+// Wrap returns the response writer implementing the optional interfaces of
+// the wrapped response writer, and only them, such as http.Hijacker for
+// websocket upgrades.
+func (w *Writer) Wrap() http.ResponseWriter {
+	var (
+		f  http.Flusher  = flusherFunc(w.flush)
+		h  http.Hijacker = hijackerFunc(w.hijack)
+		rf io.ReaderFrom = readerFromFunc(w.readFrom)
+		p  http.Pusher
+		ok bool
+		i  int
+	)
+	if _, ok = w.ResponseWriter.(http.Flusher); ok {
+		i |= flusher
+	}
+	if _, ok = w.ResponseWriter.(http.Hijacker); ok {
+		i |= hijacker
+	}
+	if _, ok = w.ResponseWriter.(io.ReaderFrom); ok {
+		i |= readerFrom
+	}
+	if p, ok = w.ResponseWriter.(http.Pusher); ok {
+		i |= pusher
+	}
+
+	switch i {
+	case flusher:
+		return struct {
+			*Writer
+			http.Flusher
+		}{w, f}
+	case hijacker:
+		return struct {
+			*Writer
+			http.Hijacker
+		}{w, h}
+	case flusher | hijacker:
+		return struct {
+			*Writer
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case readerFrom:
+		return struct {
+			*Writer
+			io.ReaderFrom
+		}{w, rf}
+	case flusher | readerFrom:
+		return struct {
+			*Writer
+			http.Flusher
+			io.ReaderFrom
+		}{w, f, rf}
+	case hijacker | readerFrom:
+		return struct {
+			*Writer
+			http.Hijacker
+			io.ReaderFrom
+		}{w, h, rf}
+	case flusher | hijacker | readerFrom:
+		return struct {
+			*Writer
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, f, h, rf}
+	case pusher:
+		return struct {
+			*Writer
+			http.Pusher
+		}{w, p}
+	case flusher | pusher:
+		return struct {
+			*Writer
+			http.Flusher
+			http.Pusher
+		}{w, f, p}
+	case hijacker | pusher:
+		return struct {
+			*Writer
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case flusher | hijacker | pusher:
+		return struct {
+			*Writer
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, f, h, p}
+	case readerFrom | pusher:
+		return struct {
+			*Writer
+			io.ReaderFrom
+			http.Pusher
+		}{w, rf, p}
+	case flusher | readerFrom | pusher:
+		return struct {
+			*Writer
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{w, f, rf, p}
+	case hijacker | readerFrom | pusher:
+		return struct {
+			*Writer
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, h, rf, p}
+	case flusher | hijacker | readerFrom | pusher:
+		return struct {
+			*Writer
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, f, h, rf, p}
+	default:
+		return struct{ *Writer }{w}
+	}
+}
+
+type flusherFunc func()
+
+func (f flusherFunc) Flush() { f() }
+
+type hijackerFunc func() (net.Conn, *bufio.ReadWriter, error)
+
+func (f hijackerFunc) Hijack() (net.Conn, *bufio.ReadWriter, error) { return f() }
+
+type readerFromFunc func(io.Reader) (int64, error)
+
+func (f readerFromFunc) ReadFrom(src io.Reader) (int64, error) { return f(src) }
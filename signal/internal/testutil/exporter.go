@@ -0,0 +1,41 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package testutil provides the test helpers shared by the SDK packages.
+package testutil
+
+import (
+	"sync"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+)
+
+// Exporter is an in-memory exporter recording the exported signals. It is
+// safe for concurrent use.
+type Exporter struct {
+	mu      sync.Mutex
+	signals []api.SignalFace
+}
+
+func (e *Exporter) Export(s api.SignalFace) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.signals = append(e.signals, s)
+}
+
+// Signals returns the signals exported so far.
+func (e *Exporter) Signals() []api.SignalFace {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]api.SignalFace(nil), e.signals...)
+}
+
+// Take returns and removes the signals exported so far.
+func (e *Exporter) Take() []api.SignalFace {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	signals := e.signals
+	e.signals = nil
+	return signals
+}
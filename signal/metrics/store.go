@@ -0,0 +1,329 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package metrics provides the metric store aggregating metric values over
+// time windows before turning them into metric signals. The memory used by
+// every metric is bounded by its cardinality limit so that metrics keyed by
+// unbounded values, such as user IDs or URL paths, cannot grow indefinitely.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+)
+
+const (
+	// DefaultMaxKeys is the maximum number of keys of a metric when its limit
+	// doesn't specify any.
+	DefaultMaxKeys = 1000
+	// DefaultOverflowKey is the key values are collapsed into when the
+	// OverflowCollapse strategy doesn't specify any.
+	DefaultOverflowKey = "__overflow__"
+	// DroppedKeysMetricName is the name of the sum metric reporting, per
+	// metric name, the number of distinct keys dropped, collapsed or evicted
+	// by cardinality limits during the window.
+	DroppedKeysMetricName = "sq.metrics.dropped_keys"
+	// droppedKeysFactor bounds the number of distinct dropped keys tracked
+	// per window to this factor of the maximum number of keys.
+	droppedKeysFactor = 16
+)
+
+// OverflowStrategy is the strategy applied to values of new keys once a metric
+// reached its maximum number of keys.
+type OverflowStrategy int
+
+const (
+	// OverflowDrop drops values of new keys.
+	OverflowDrop OverflowStrategy = iota
+	// OverflowCollapse adds values of new keys to the overflow key.
+	OverflowCollapse
+	// OverflowSample randomly replaces existing keys by new ones with a
+	// probability decreasing as more new keys are seen during the window. The
+	// resulting keys are therefore a random sample of the keys updated during
+	// the window, biased towards the most frequently updated ones.
+	OverflowSample
+)
+
+// Limit is the cardinality limit of a metric.
+type Limit struct {
+	// MaxKeys is the maximum number of keys the metric can have during a
+	// window. DefaultMaxKeys is used when zero.
+	MaxKeys int
+	// Overflow is the strategy applied to values of new keys once MaxKeys is
+	// reached.
+	Overflow OverflowStrategy
+	// OverflowKey is the key values are collapsed into with the
+	// OverflowCollapse strategy. DefaultOverflowKey is used when empty. It
+	// doesn't count in MaxKeys.
+	OverflowKey string
+}
+
+func (l Limit) maxKeys() int {
+	if l.MaxKeys <= 0 {
+		return DefaultMaxKeys
+	}
+	return l.MaxKeys
+}
+
+func (l Limit) overflowKey() string {
+	if l.OverflowKey == "" {
+		return DefaultOverflowKey
+	}
+	return l.OverflowKey
+}
+
+// Store is a set of metrics aggregated over the same time window. It is safe
+// for concurrent use.
+type Store struct {
+	source  string
+	period  time.Duration
+	mu      sync.Mutex
+	started time.Time
+	sums    map[string]*Sum
+	bins    map[string]*Binning
+}
+
+// NewStore returns a new metric store whose metrics have the given signal
+// source and are expected to be flushed every period.
+func NewStore(source string, period time.Duration) *Store {
+	return &Store{
+		source:  source,
+		period:  period,
+		started: time.Now(),
+		sums:    make(map[string]*Sum),
+		bins:    make(map[string]*Binning),
+	}
+}
+
+// Sum returns the sum metric of the given name, creating it with the given
+// cardinality limit if it doesn't exist yet.
+func (s *Store) Sum(name string, limit Limit) *Sum {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, exists := s.sums[name]; exists {
+		return m
+	}
+	m := newSum(name, limit)
+	s.sums[name] = m
+	return m
+}
+
+// Binning returns the binning metric of the given name, creating it with the
+// given base and unit if it doesn't exist yet. It panics when the base isn't
+// greater than 1 or the unit isn't positive.
+func (s *Store) Binning(name string, base, unit float64) *Binning {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, exists := s.bins[name]; exists {
+		return m
+	}
+	m := newBinning(base, unit)
+	s.bins[name] = m
+	return m
+}
+
+// Run flushes the store into the given exporter every period until the
+// context is canceled. The current window is flushed before returning.
+func (s *Store) Run(ctx context.Context, e client.Exporter) {
+	ticker := time.NewTicker(s.period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.export(e, time.Now())
+			return
+		case now := <-ticker.C:
+			s.export(e, now)
+		}
+	}
+}
+
+func (s *Store) export(e client.Exporter, now time.Time) {
+	for _, m := range s.Flush(now) {
+		e.Export(m)
+	}
+}
+
+// Flush returns the metric signals of the current window ending at the given
+// time, and starts a new window. Metrics without values during the window are
+// omitted. A DroppedKeysMetricName metric is added when cardinality limits
+// dropped keys during the window.
+func (s *Store) Flush(now time.Time) []*api.Metric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	started := s.started
+	s.started = now
+
+	var metrics []*api.Metric
+	var dropped map[string]int64
+	for name, m := range s.sums {
+		values, d := m.flush()
+		if len(values) > 0 {
+			metrics = append(metrics, api.NewSumMetric(name, s.source, started, now, s.period, values))
+		}
+		if d > 0 {
+			if dropped == nil {
+				dropped = make(map[string]int64)
+			}
+			dropped[name] = d
+		}
+	}
+	for name, m := range s.bins {
+		if bins, max := m.flush(); len(bins) > 0 {
+			metrics = append(metrics, api.NewBinningMetric(name, s.source, started, now, s.period, m.base, m.unit, bins, max))
+		}
+	}
+	if dropped != nil {
+		metrics = append(metrics, api.NewSumMetric(DroppedKeysMetricName, s.source, started, now, s.period, dropped))
+	}
+	return metrics
+}
+
+// Sum is a metric summing the values added per key during the window. It is
+// safe for concurrent use.
+type Sum struct {
+	name   string
+	limit  Limit
+	mu     sync.Mutex
+	values map[string]int64
+	keys   []string
+	seen   int64
+	// droppedKeys are the hashes of the distinct keys dropped during the
+	// window, up to droppedKeysFactor times the maximum number of keys. Once
+	// full, every value of a new key counts as a dropped key.
+	droppedKeys map[uint64]struct{}
+	dropped     int64
+}
+
+func newSum(name string, limit Limit) *Sum {
+	return &Sum{
+		name:        name,
+		limit:       limit,
+		values:      make(map[string]int64),
+		droppedKeys: make(map[uint64]struct{}),
+	}
+}
+
+// Add adds delta to the value of the given key according to the cardinality
+// limit of the metric.
+func (m *Sum) Add(key string, delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.values[key]; exists {
+		m.values[key] += delta
+		return
+	}
+
+	maxKeys := m.limit.maxKeys()
+	if len(m.keys) < maxKeys {
+		m.insert(key, delta)
+		return
+	}
+
+	m.drop(key, maxKeys)
+	switch m.limit.Overflow {
+	case OverflowCollapse:
+		m.values[m.limit.overflowKey()] += delta
+	case OverflowSample:
+		// Reservoir sampling over the new keys of the window.
+		m.seen++
+		if i := rand.Int63n(int64(maxKeys) + m.seen); i < int64(maxKeys) {
+			delete(m.values, m.keys[i])
+			m.keys[i] = key
+			m.values[key] = delta
+		}
+	}
+}
+
+// drop counts the key as dropped when it wasn't already during the window.
+func (m *Sum) drop(key string, maxKeys int) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	if _, seen := m.droppedKeys[sum]; seen {
+		return
+	}
+	if len(m.droppedKeys) < droppedKeysFactor*maxKeys {
+		m.droppedKeys[sum] = struct{}{}
+	}
+	m.dropped++
+}
+
+func (m *Sum) insert(key string, delta int64) {
+	m.keys = append(m.keys, key)
+	m.values[key] = delta
+}
+
+func (m *Sum) flush() (values map[string]int64, dropped int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	values, dropped = m.values, m.dropped
+	m.values = make(map[string]int64, len(values))
+	m.keys = m.keys[:0]
+	m.seen = 0
+	m.droppedKeys = make(map[uint64]struct{})
+	m.dropped = 0
+	return values, dropped
+}
+
+// Binning is a metric counting the values added during the window per bin of
+// exponentially growing widths. Bin 0 counts the values lower than the unit,
+// while bin i > 0 counts the values in [unit*base^(i-1), unit*base^i). It is
+// safe for concurrent use.
+type Binning struct {
+	base, unit float64
+	logBase    float64
+	mu         sync.Mutex
+	bins       map[string]int64
+	max        float64
+}
+
+func newBinning(base, unit float64) *Binning {
+	// Written so that NaN values are also rejected
+	if !(base > 1) || !(unit > 0) || math.IsInf(base, 0) || math.IsInf(unit, 0) {
+		panic(fmt.Sprintf("metrics: invalid binning base %g or unit %g", base, unit))
+	}
+	return &Binning{
+		base:    base,
+		unit:    unit,
+		logBase: math.Log(base),
+		bins:    make(map[string]int64),
+	}
+}
+
+// Add adds the value to its bin.
+func (m *Binning) Add(v float64) {
+	bin := 0
+	if v >= m.unit {
+		bin = 1 + int(math.Floor(math.Log(v/m.unit)/m.logBase))
+	}
+	key := strconv.Itoa(bin)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bins[key]++
+	if v > m.max {
+		m.max = v
+	}
+}
+
+func (m *Binning) flush() (bins map[string]int64, max float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bins, max = m.bins, m.max
+	m.bins = make(map[string]int64, len(bins))
+	m.max = 0
+	return bins, max
+}
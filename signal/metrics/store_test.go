@@ -0,0 +1,176 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package metrics_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	t.Run("sum", func(t *testing.T) {
+		store := metrics.NewStore("test", time.Minute)
+		sum := store.Sum("my metric", metrics.Limit{})
+		require.Same(t, sum, store.Sum("my metric", metrics.Limit{}))
+
+		sum.Add("a", 1)
+		sum.Add("b", 2)
+		sum.Add("a", 3)
+
+		now := time.Now()
+		flushed := store.Flush(now)
+		require.Len(t, flushed, 1)
+		require.Equal(t, "my metric", flushed[0].Name)
+		require.Equal(t, "test", flushed[0].Source)
+		require.Equal(t, map[string]int64{"a": 4, "b": 2}, sumValues(t, flushed[0]))
+
+		t.Run("empty window", func(t *testing.T) {
+			require.Empty(t, store.Flush(now.Add(time.Minute)))
+		})
+	})
+
+	t.Run("cardinality limits", func(t *testing.T) {
+		for _, tc := range []struct {
+			name     string
+			limit    metrics.Limit
+			expected map[string]int64
+		}{
+			{
+				name:     "drop",
+				limit:    metrics.Limit{MaxKeys: 2, Overflow: metrics.OverflowDrop},
+				expected: map[string]int64{"k0": 2, "k1": 1},
+			},
+			{
+				name:     "collapse",
+				limit:    metrics.Limit{MaxKeys: 2, Overflow: metrics.OverflowCollapse},
+				expected: map[string]int64{"k0": 2, "k1": 1, metrics.DefaultOverflowKey: 4},
+			},
+			{
+				name:     "collapse with overflow key",
+				limit:    metrics.Limit{MaxKeys: 2, Overflow: metrics.OverflowCollapse, OverflowKey: "other"},
+				expected: map[string]int64{"k0": 2, "k1": 1, "other": 4},
+			},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				store := metrics.NewStore("test", time.Minute)
+				sum := store.Sum("my metric", tc.limit)
+				for i := 0; i < 5; i++ {
+					sum.Add(fmt.Sprintf("k%d", i), 1)
+				}
+				sum.Add("k0", 1)
+				// Dropped keys are counted once per window
+				sum.Add("k4", 1)
+
+				flushed := store.Flush(time.Now())
+				require.Len(t, flushed, 2)
+				require.Equal(t, tc.expected, sumValues(t, flushed[0]))
+				require.Equal(t, metrics.DroppedKeysMetricName, flushed[1].Name)
+				require.Equal(t, map[string]int64{"my metric": 3}, sumValues(t, flushed[1]))
+			})
+		}
+
+		t.Run("sample", func(t *testing.T) {
+			store := metrics.NewStore("test", time.Minute)
+			sum := store.Sum("my metric", metrics.Limit{MaxKeys: 10, Overflow: metrics.OverflowSample})
+			for i := 0; i < 1000; i++ {
+				sum.Add(fmt.Sprintf("k%d", i), 1)
+			}
+
+			flushed := store.Flush(time.Now())
+			require.Len(t, flushed, 2)
+			require.Len(t, sumValues(t, flushed[0]), 10)
+			require.Equal(t, map[string]int64{"my metric": 990}, sumValues(t, flushed[1]))
+		})
+
+		t.Run("default limit", func(t *testing.T) {
+			store := metrics.NewStore("test", time.Minute)
+			sum := store.Sum("my metric", metrics.Limit{})
+			for i := 0; i < metrics.DefaultMaxKeys+1; i++ {
+				sum.Add(fmt.Sprintf("k%d", i), 1)
+			}
+			flushed := store.Flush(time.Now())
+			require.Len(t, flushed, 2)
+			require.Len(t, sumValues(t, flushed[0]), metrics.DefaultMaxKeys)
+		})
+
+		t.Run("limits are per window", func(t *testing.T) {
+			store := metrics.NewStore("test", time.Minute)
+			sum := store.Sum("my metric", metrics.Limit{MaxKeys: 1})
+			sum.Add("a", 1)
+			sum.Add("b", 1)
+			require.Len(t, store.Flush(time.Now()), 2)
+
+			sum.Add("b", 1)
+			flushed := store.Flush(time.Now())
+			require.Len(t, flushed, 1)
+			require.Equal(t, map[string]int64{"b": 1}, sumValues(t, flushed[0]))
+		})
+	})
+}
+
+func TestBinning(t *testing.T) {
+	t.Run("bins", func(t *testing.T) {
+		store := metrics.NewStore("test", time.Minute)
+		b := store.Binning("my binning", 2, 10)
+		require.Same(t, b, store.Binning("my binning", 2, 10))
+
+		for _, v := range []float64{0, 9.9, 10, 19.9, 20, 39.9, 40, 1000} {
+			b.Add(v)
+		}
+
+		flushed := store.Flush(time.Now())
+		require.Len(t, flushed, 1)
+		require.Equal(t, "my binning", flushed[0].Name)
+		require.Equal(t, "test", flushed[0].Source)
+		payload, ok := flushed[0].Payload.(api.BinningMetricsSignalPayload)
+		require.True(t, ok)
+		require.Equal(t, 2.0, payload.Base)
+		require.Equal(t, 10.0, payload.Unit)
+		require.Equal(t, 1000.0, payload.Max)
+		require.Equal(t, map[string]int64{"0": 2, "1": 2, "2": 2, "3": 1, "7": 1}, payload.Bins)
+
+		t.Run("empty window", func(t *testing.T) {
+			require.Empty(t, store.Flush(time.Now()))
+		})
+	})
+
+	t.Run("invalid base or unit", func(t *testing.T) {
+		for _, tc := range []struct {
+			name       string
+			base, unit float64
+		}{
+			{"base 1", 1, 1},
+			{"base lower than 1", 0.5, 1},
+			{"base NaN", math.NaN(), 1},
+			{"base infinite", math.Inf(1), 1},
+			{"zero unit", 2, 0},
+			{"negative unit", 2, -1},
+			{"unit NaN", 2, math.NaN()},
+		} {
+			tc := tc
+			t.Run(tc.name, func(t *testing.T) {
+				store := metrics.NewStore("test", time.Minute)
+				require.Panics(t, func() { store.Binning("my binning", tc.base, tc.unit) })
+			})
+		}
+	})
+}
+
+func sumValues(t *testing.T, m *api.Metric) map[string]int64 {
+	payload, ok := m.Payload.(api.MetricSignalPayload)
+	require.True(t, ok)
+	values := make(map[string]int64, len(payload.Values))
+	for _, e := range payload.Values {
+		values[e.Key] = e.Value
+	}
+	return values
+}
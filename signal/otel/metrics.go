@@ -0,0 +1,260 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package otel
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const (
+	// DefaultKeyAttribute is the attribute of the sum metric keys when the
+	// producer doesn't specify any.
+	DefaultKeyAttribute = "key"
+	// DefaultMaxPendingMetrics is the maximum number of metrics waiting to be
+	// produced when the producer doesn't specify any.
+	DefaultMaxPendingMetrics = 1000
+	// scopeName is the instrumentation scope of metrics without source.
+	scopeName = "github.com/sqreen/go-sdk/signal/otel"
+)
+
+// MetricProducer converts the SDK metrics into OpenTelemetry metrics. It is
+// an exporter receiving the metrics of a metrics.Store, possibly along with
+// another exporter (cf. client.MultiExporter), and an OpenTelemetry metric
+// producer to register into a reader using sdkmetric.WithProducer().
+//
+// Sum metrics are converted into delta monotonic sums, aka. counters, having
+// one data point per key. Binning metrics are converted into delta exponential
+// histograms of the same values, so that bin 0 is the zero bucket whose
+// threshold is the binning unit and bin i > 0 is a bucket, OpenTelemetry
+// buckets only differing from the bins by counting the values equal to their
+// lower bound in the previous bucket. This holds for the exponential histogram
+// bases, ie. 2^(2^-n) for an integer scale n, such as 2 or 4, and units being
+// powers of 2. Other binnings are re-bucketed into the nearest scale, each bin
+// being counted in the bucket of its geometric midpoint. The sum of the
+// histogram values is estimated from the middle of the bins, bounded by the
+// max. Metrics are grouped in instrumentation scopes named after their source.
+// Metrics that cannot be converted are counted as dropped (cf. Dropped()).
+type MetricProducer struct {
+	// KeyAttribute is the attribute of the sum metric keys.
+	// DefaultKeyAttribute is used when empty.
+	KeyAttribute string
+	// MaxPendingMetrics is the maximum number of metrics waiting to be
+	// produced. Metrics exported while the limit is reached are dropped.
+	// DefaultMaxPendingMetrics is used when zero.
+	MaxPendingMetrics int
+
+	mu      sync.Mutex
+	pending []*api.Metric
+	dropped uint64
+}
+
+var _ sdkmetric.Producer = (*MetricProducer)(nil)
+
+// NewMetricProducer returns a new metric producer.
+func NewMetricProducer() *MetricProducer {
+	return &MetricProducer{}
+}
+
+// Export adds the metric to the metrics to produce. Other signals are ignored.
+func (p *MetricProducer) Export(s api.SignalFace) {
+	m, ok := s.(*api.Metric)
+	if !ok {
+		return
+	}
+	max := p.MaxPendingMetrics
+	if max <= 0 {
+		max = DefaultMaxPendingMetrics
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) < max {
+		p.pending = append(p.pending, m)
+		return
+	}
+	atomic.AddUint64(&p.dropped, 1)
+}
+
+// Dropped returns the number of metrics dropped so far, either because the
+// pending metrics limit was reached or because they couldn't be converted.
+func (p *MetricProducer) Dropped() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+// Produce returns the OpenTelemetry metrics converted from the metrics
+// exported since the previous call.
+func (p *MetricProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = nil
+	p.mu.Unlock()
+
+	keyAttribute := p.KeyAttribute
+	if keyAttribute == "" {
+		keyAttribute = DefaultKeyAttribute
+	}
+
+	var scopes []metricdata.ScopeMetrics
+	index := make(map[string]int)
+	for _, m := range pending {
+		converted, ok := convertMetric(m, keyAttribute)
+		if !ok {
+			atomic.AddUint64(&p.dropped, 1)
+			continue
+		}
+		scope := m.Source
+		if scope == "" {
+			scope = scopeName
+		}
+		i, exists := index[scope]
+		if !exists {
+			i = len(scopes)
+			index[scope] = i
+			scopes = append(scopes, metricdata.ScopeMetrics{Scope: instrumentation.Scope{Name: scope}})
+		}
+		scopes[i].Metrics = append(scopes[i].Metrics, converted)
+	}
+	return scopes, nil
+}
+
+func convertMetric(m *api.Metric, keyAttribute string) (metricdata.Metrics, bool) {
+	if m.SignalPayload == nil {
+		return metricdata.Metrics{}, false
+	}
+	switch payload := m.SignalPayload.Payload.(type) {
+	case api.MetricSignalPayload:
+		return metricdata.Metrics{
+			Name: m.Name,
+			Data: convertSum(payload, keyAttribute),
+		}, true
+
+	case api.BinningMetricsSignalPayload:
+		h, ok := convertBinning(payload)
+		if !ok {
+			return metricdata.Metrics{}, false
+		}
+		return metricdata.Metrics{
+			Name: m.Name,
+			Data: h,
+		}, true
+
+	default:
+		return metricdata.Metrics{}, false
+	}
+}
+
+func convertSum(payload api.MetricSignalPayload, keyAttribute string) metricdata.Sum[int64] {
+	points := make([]metricdata.DataPoint[int64], 0, len(payload.Values))
+	for _, v := range payload.Values {
+		points = append(points, metricdata.DataPoint[int64]{
+			Attributes: attribute.NewSet(attribute.String(keyAttribute, v.Key)),
+			StartTime:  payload.DateStarted,
+			Time:       payload.DateEnded,
+			Value:      v.Value,
+		})
+	}
+	// Sort the data points by key for deterministic outputs
+	sort.Slice(points, func(i, j int) bool {
+		ki, _ := points[i].Attributes.Value(attribute.Key(keyAttribute))
+		kj, _ := points[j].Attributes.Value(attribute.Key(keyAttribute))
+		return ki.AsString() < kj.AsString()
+	})
+	return metricdata.Sum[int64]{
+		DataPoints:  points,
+		Temporality: metricdata.DeltaTemporality,
+		IsMonotonic: true,
+	}
+}
+
+func convertBinning(payload api.BinningMetricsSignalPayload) (metricdata.ExponentialHistogram[float64], bool) {
+	base := payload.Base
+	if !(base > 1) || !(payload.Unit > 0) || math.IsInf(base, 0) || math.IsInf(payload.Unit, 0) {
+		return metricdata.ExponentialHistogram[float64]{}, false
+	}
+	scale := histogramScale(base)
+	max := payload.Max
+
+	point := metricdata.ExponentialHistogramDataPoint[float64]{
+		Attributes:    *attribute.EmptySet(),
+		StartTime:     payload.DateStarted,
+		Time:          payload.DateEnded,
+		Scale:         scale,
+		ZeroThreshold: payload.Unit,
+		Max:           metricdata.NewExtrema(max),
+	}
+	counts := make(map[int32]int64, len(payload.Bins))
+	minIndex, maxIndex := int32(math.MaxInt32), int32(math.MinInt32)
+	for k, n := range payload.Bins {
+		bin, err := strconv.Atoi(k)
+		if err != nil || bin < 0 || n <= 0 {
+			continue
+		}
+		point.Count += uint64(n)
+		point.Sum += float64(n) * math.Min(binMidpoint(base, bin)*payload.Unit, max)
+		if bin == 0 {
+			point.ZeroCount += uint64(n)
+			continue
+		}
+		index := bucketIndex(base, payload.Unit, scale, bin)
+		counts[index] += n
+		if index < minIndex {
+			minIndex = index
+		}
+		if index > maxIndex {
+			maxIndex = index
+		}
+	}
+	if len(counts) > 0 {
+		point.PositiveBucket.Offset = minIndex
+		point.PositiveBucket.Counts = make([]uint64, maxIndex-minIndex+1)
+		for index, n := range counts {
+			point.PositiveBucket.Counts[index-minIndex] = uint64(n)
+		}
+	}
+	return metricdata.ExponentialHistogram[float64]{
+		DataPoints:  []metricdata.ExponentialHistogramDataPoint[float64]{point},
+		Temporality: metricdata.DeltaTemporality,
+	}, true
+}
+
+// Bounds of the exponential histogram scales.
+const (
+	minScale = -10
+	maxScale = 20
+)
+
+// histogramScale returns the exponential histogram scale of the nearest base
+// to the given one, ie. the integer n such that 2^(2^-n) is the closest to
+// base on a logarithmic scale.
+func histogramScale(base float64) int32 {
+	n := math.Round(-math.Log2(math.Log2(base)))
+	return int32(math.Max(minScale, math.Min(maxScale, n)))
+}
+
+// bucketIndex returns the index of the exponential histogram bucket of the
+// given scale containing the geometric midpoint of the bin i > 0. It is i-1
+// when base is the base of the scale and unit is 1.
+func bucketIndex(base, unit float64, scale int32, i int) int32 {
+	v := (math.Log2(unit) + (float64(i)-0.5)*math.Log2(base)) * math.Exp2(float64(scale))
+	return int32(math.Ceil(v)) - 1
+}
+
+// binMidpoint returns the middle of the bin i, in multiples of the unit.
+func binMidpoint(base float64, i int) float64 {
+	if i == 0 {
+		return 0.5
+	}
+	return (math.Pow(base, float64(i-1)) + math.Pow(base, float64(i))) / 2
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package otel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/metrics"
+	sqotel "github.com/sqreen/go-sdk/signal/otel"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetricProducer(t *testing.T) {
+	producer := sqotel.NewMetricProducer()
+	reader := sdkmetric.NewManualReader(sdkmetric.WithProducer(producer))
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	var other testutil.Exporter
+	exporter := client.MultiExporter{producer, &other}
+
+	store := metrics.NewStore("test", time.Minute)
+	sum := store.Sum("my sum", metrics.Limit{})
+	sum.Add("b", 2)
+	sum.Add("a", 1)
+	sum.Add("a", 3)
+	binning := store.Binning("my binning", 2, 0.5)
+	for _, v := range []float64{0.1, 0.6, 0.7, 1.5, 2.5, 2.9, 3} {
+		binning.Add(v)
+	}
+	store.Binning("other base", 10, 1).Add(42)
+	start := time.Now()
+	end := start.Add(time.Minute)
+	for _, m := range store.Flush(end) {
+		exporter.Export(m)
+	}
+	exporter.Export(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, nil))
+	require.Len(t, other.Signals(), 4)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Equal(t, "test", rm.ScopeMetrics[0].Scope.Name)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 3)
+
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch m.Name {
+		case "my sum":
+			data := m.Data.(metricdata.Sum[int64])
+			require.Equal(t, metricdata.DeltaTemporality, data.Temporality)
+			require.True(t, data.IsMonotonic)
+			require.Len(t, data.DataPoints, 2)
+			require.Equal(t, attribute.NewSet(attribute.String("key", "a")), data.DataPoints[0].Attributes)
+			require.Equal(t, int64(4), data.DataPoints[0].Value)
+			require.Equal(t, attribute.NewSet(attribute.String("key", "b")), data.DataPoints[1].Attributes)
+			require.Equal(t, int64(2), data.DataPoints[1].Value)
+			require.Equal(t, end, data.DataPoints[0].Time)
+
+		case "my binning":
+			data := m.Data.(metricdata.ExponentialHistogram[float64])
+			require.Equal(t, metricdata.DeltaTemporality, data.Temporality)
+			require.Len(t, data.DataPoints, 1)
+			p := data.DataPoints[0]
+			require.Equal(t, int32(0), p.Scale)
+			require.Equal(t, uint64(7), p.Count)
+			// Bins of unit 0.5: 0.1 | 0.6, 0.7 | 1.5 | 2.5, 2.9, 3
+			require.Equal(t, 0.5, p.ZeroThreshold)
+			require.Equal(t, uint64(1), p.ZeroCount)
+			require.Equal(t, int32(-1), p.PositiveBucket.Offset)
+			require.Equal(t, []uint64{2, 1, 3}, p.PositiveBucket.Counts)
+			max, ok := p.Max.Value()
+			require.True(t, ok)
+			require.Equal(t, 3.0, max)
+			// Estimated from the middle of the bins: 0.25 | 0.75 | 1.5 | 3
+			require.Equal(t, 12.25, p.Sum)
+
+		case "other base":
+			p := m.Data.(metricdata.ExponentialHistogram[float64]).DataPoints[0]
+			// Re-bucketed into the nearest scale of base 16
+			require.Equal(t, int32(-2), p.Scale)
+			require.Equal(t, uint64(1), p.Count)
+			require.Equal(t, int32(1), p.PositiveBucket.Offset)
+			require.Equal(t, []uint64{1}, p.PositiveBucket.Counts)
+			// The bin middle is bounded by the max
+			require.Equal(t, 42.0, p.Sum)
+
+		default:
+			t.Fatalf("unexpected metric %q", m.Name)
+		}
+	}
+
+	t.Run("produced once", func(t *testing.T) {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		require.Empty(t, rm.ScopeMetrics)
+	})
+
+	t.Run("max pending metrics", func(t *testing.T) {
+		producer.MaxPendingMetrics = 1
+		defer func() { producer.MaxPendingMetrics = 0 }()
+		for i := 0; i < 3; i++ {
+			producer.Export(api.NewSumMetric("my sum", "test", start, end, time.Minute, map[string]int64{"a": 1}))
+		}
+		scopes, err := producer.Produce(context.Background())
+		require.NoError(t, err)
+		require.Len(t, scopes, 1)
+		require.Len(t, scopes[0].Metrics, 1)
+		require.Equal(t, uint64(2), producer.Dropped())
+	})
+
+	t.Run("invalid binning", func(t *testing.T) {
+		dropped := producer.Dropped()
+		producer.Export(api.NewBinningMetric("my binning", "test", start, end, time.Minute, 1, 1, map[string]int64{"1": 1}, 1))
+		scopes, err := producer.Produce(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, scopes)
+		require.Equal(t, dropped+1, producer.Dropped())
+	})
+}
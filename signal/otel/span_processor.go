@@ -0,0 +1,193 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package otel bridges OpenTelemetry instrumentations with the SDK so that
+// applications already instrumented with OpenTelemetry don't need to be
+// instrumented twice.
+package otel
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventPayloadSchema is the payload schema of the points converted from span
+// events. The payload is the map of the event attributes.
+const EventPayloadSchema = "otel_event/2020-01-01T00:00:00.000Z"
+
+// requestHeaderPrefix is the attribute key prefix of the captured request
+// headers.
+const requestHeaderPrefix = "http.request.header."
+
+// SpanProcessor is an OpenTelemetry span processor converting the finished
+// HTTP server spans into HTTP traces. The span attributes following the HTTP
+// semantic conventions, in their current or former versions, are mapped onto
+// the trace request and response contexts, and the span events having the
+// configured prefix are converted into the trace points.
+type SpanProcessor struct {
+	// Source is the signal source of the traces and points.
+	Source string
+	// EventPrefix is the name prefix of the span events converted into
+	// points, the prefix being removed from the point names. No events are
+	// converted when empty.
+	EventPrefix string
+	// Headers is the policy of the capture of the request headers recorded
+	// in the span attributes. sqhttp.DefaultHeaderPolicy is used when nil.
+	Headers *sqhttp.HeaderPolicy
+
+	exporter client.Exporter
+}
+
+var _ sdktrace.SpanProcessor = (*SpanProcessor)(nil)
+
+// NewSpanProcessor returns a new span processor exporting the traces with the
+// given exporter.
+func NewSpanProcessor(exporter client.Exporter) *SpanProcessor {
+	return &SpanProcessor{exporter: exporter}
+}
+
+func (p *SpanProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+// OnEnd exports the HTTP trace of the span when it is an HTTP server span.
+func (p *SpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanKind() != trace.SpanKindServer {
+		return
+	}
+	attrs := newAttributes(s.Attributes())
+	verb := attrs.string("http.request.method", "http.method")
+	if verb == "" {
+		return
+	}
+
+	path := attrs.string("url.path", "http.target")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	reqCtx := sqhttp.NewRequestContext(
+		s.StartTime(),
+		s.EndTime(),
+		"",
+		p.headers(attrs),
+		attrs.string("user_agent.original", "http.user_agent"),
+		attrs.string("url.scheme", "http.scheme"),
+		verb,
+		attrs.string("server.address", "net.host.name", "http.host"),
+		attrs.string("client.address", "http.client_ip", "net.sock.peer.addr"),
+		path,
+		attrs.header("referer"),
+		uint64(attrs.int("server.port", "net.host.port")),
+		uint64(attrs.int("client.port", "net.sock.peer.port")),
+		nil,
+	)
+	reqCtx.Route = attrs.string("http.route")
+	respCtx := sqhttp.NewResponseContext(
+		int(attrs.int("http.response.status_code", "http.status_code")),
+		"",
+		attrs.int("http.response.body.size", "http.response_content_length"),
+	)
+
+	var ips []string
+	if reqCtx.RemoteIP != "" {
+		ips = []string{reqCtx.RemoteIP}
+	}
+	actor := sqhttp.NewActor(ips, reqCtx.UserAgent, nil)
+	tr := sqhttp.NewTrace(p.Source, s.StartTime(), actor, nil, sqhttp.NewContext(reqCtx, respCtx), p.points(s.Events()))
+	tr.TraceID = s.SpanContext().TraceID().String()
+	tr.SpanID = s.SpanContext().SpanID().String()
+	if parent := s.Parent(); parent.IsValid() {
+		tr.ParentID = parent.SpanID().String()
+	}
+	p.exporter.Export(tr)
+}
+
+func (p *SpanProcessor) points(events []sdktrace.Event) []*api.Signal {
+	if p.EventPrefix == "" {
+		return nil
+	}
+	var points []*api.Signal
+	for _, e := range events {
+		if !strings.HasPrefix(e.Name, p.EventPrefix) {
+			continue
+		}
+		payload := make(map[string]interface{}, len(e.Attributes))
+		for _, kv := range e.Attributes {
+			payload[string(kv.Key)] = kv.Value.AsInterface()
+		}
+		point := api.NewPoint(strings.TrimPrefix(e.Name, p.EventPrefix), p.Source, e.Time, nil, nil, nil, nil, nil, api.NewPayload(EventPayloadSchema, payload))
+		points = append(points, (*api.Signal)(point))
+	}
+	return points
+}
+
+func (p *SpanProcessor) Shutdown(context.Context) error   { return nil }
+func (p *SpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// attributes indexes span attributes by key.
+type attributes map[attribute.Key]attribute.Value
+
+func newAttributes(kvs []attribute.KeyValue) attributes {
+	attrs := make(attributes, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+// string returns the value of the first of the keys found.
+func (a attributes) string(keys ...attribute.Key) string {
+	for _, k := range keys {
+		if v, ok := a[k]; ok {
+			return v.Emit()
+		}
+	}
+	return ""
+}
+
+// int returns the value of the first of the integer keys found.
+func (a attributes) int(keys ...attribute.Key) int64 {
+	for _, k := range keys {
+		if v, ok := a[k]; ok && v.Type() == attribute.INT64 {
+			return v.AsInt64()
+		}
+	}
+	return 0
+}
+
+// header returns the first value of the captured request header.
+func (a attributes) header(name string) string {
+	if v, ok := a[attribute.Key(requestHeaderPrefix+name)]; ok {
+		if values := v.AsStringSlice(); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// headers returns the request headers recorded by the instrumentations as
+// string slice attributes, captured according to the header policy.
+func (p *SpanProcessor) headers(a attributes) [][]string {
+	headers := make(http.Header)
+	for k, v := range a {
+		name := strings.TrimPrefix(string(k), requestHeaderPrefix)
+		if len(name) == len(k) || v.Type() != attribute.STRINGSLICE {
+			continue
+		}
+		// Former semantic conventions replaced dashes with underscores
+		name = strings.ReplaceAll(name, "_", "-")
+		headers[http.CanonicalHeaderKey(name)] = v.AsStringSlice()
+	}
+	policy := p.Headers
+	if policy == nil {
+		policy = sqhttp.DefaultHeaderPolicy
+	}
+	return policy.Capture(headers)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package otel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	sqhttp "github.com/sqreen/go-sdk/signal/http"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	sqotel "github.com/sqreen/go-sdk/signal/otel"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSpanProcessor(t *testing.T) {
+	var exporter testutil.Exporter
+	p := sqotel.NewSpanProcessor(&exporter)
+	p.Source = "test"
+	p.EventPrefix = "security."
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	t.Run("server span", func(t *testing.T) {
+		parent, parentSpan := tracer.Start(context.Background(), "parent")
+		_, span := tracer.Start(parent, "GET /users/{id}", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.request.method", "GET"),
+			attribute.String("url.path", "/users/42"),
+			attribute.String("url.scheme", "https"),
+			attribute.String("http.route", "/users/{id}"),
+			attribute.String("server.address", "example.com"),
+			attribute.Int("server.port", 443),
+			attribute.String("client.address", "1.2.3.4"),
+			attribute.Int("client.port", 1234),
+			attribute.String("user_agent.original", "my ua"),
+			attribute.Int("http.response.status_code", 404),
+			attribute.StringSlice("http.request.header.accept", []string{"*/*"}),
+			attribute.StringSlice("http.request.header.authorization", []string{"secret"}),
+			attribute.StringSlice("http.request.header.referer", []string{"https://example.com/"}),
+		))
+		span.AddEvent("security.login_failure", trace.WithAttributes(attribute.String("user", "bob")))
+		span.AddEvent("other event")
+		span.End()
+		parentSpan.End()
+
+		traces := takeTraces(&exporter)
+		require.Len(t, traces, 1)
+		tr := traces[0]
+		require.Equal(t, "test", tr.Source)
+		require.Equal(t, span.SpanContext().TraceID().String(), tr.TraceID)
+		require.Equal(t, span.SpanContext().SpanID().String(), tr.SpanID)
+		require.Equal(t, parentSpan.SpanContext().SpanID().String(), tr.ParentID)
+		require.Equal(t, &sqhttp.Actor{IPAddresses: []string{"1.2.3.4"}, UserAgent: "my ua"}, tr.Actor)
+
+		ctx := tr.Context.(*sqhttp.Context)
+		require.Equal(t, "GET", ctx.Request.Verb)
+		require.Equal(t, "/users/42", ctx.Request.Path)
+		require.Equal(t, "/users/{id}", ctx.Request.Route)
+		require.Equal(t, "https", ctx.Request.Scheme)
+		require.Equal(t, "example.com", ctx.Request.Host)
+		require.Equal(t, uint64(443), ctx.Request.Port)
+		require.Equal(t, "1.2.3.4", ctx.Request.RemoteIP)
+		require.Equal(t, uint64(1234), ctx.Request.RemotePort)
+		require.Equal(t, "https://example.com/", ctx.Request.Referer)
+		require.Equal(t, [][]string{{"Accept", "*/*"}, {"Referer", "https://example.com/"}}, ctx.Request.Headers)
+		require.Equal(t, 404, ctx.Response.Status)
+		require.False(t, ctx.Request.End.Before(ctx.Request.Start))
+
+		require.Len(t, tr.Data, 1)
+		require.Equal(t, "login_failure", tr.Data[0].Name)
+		require.Equal(t, "test", tr.Data[0].Source)
+		require.Equal(t, api.NewPayload(sqotel.EventPayloadSchema, map[string]interface{}{"user": "bob"}), tr.Data[0].SignalPayload)
+	})
+
+	t.Run("former semantic conventions", func(t *testing.T) {
+		_, span := tracer.Start(context.Background(), "POST", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("http.method", "POST"),
+			attribute.String("http.target", "/login?next=/"),
+			attribute.String("http.scheme", "http"),
+			attribute.String("net.host.name", "example.com"),
+			attribute.Int("net.host.port", 8080),
+			attribute.String("http.client_ip", "5.6.7.8"),
+			attribute.String("http.user_agent", "my ua"),
+			attribute.Int("http.status_code", 200),
+			attribute.StringSlice("http.request.header.x_forwarded_for", []string{"5.6.7.8"}),
+		))
+		span.End()
+
+		traces := takeTraces(&exporter)
+		require.Len(t, traces, 1)
+		ctx := traces[0].Context.(*sqhttp.Context)
+		require.Equal(t, "POST", ctx.Request.Verb)
+		require.Equal(t, "/login", ctx.Request.Path)
+		require.Equal(t, "http", ctx.Request.Scheme)
+		require.Equal(t, "example.com", ctx.Request.Host)
+		require.Equal(t, uint64(8080), ctx.Request.Port)
+		require.Equal(t, "5.6.7.8", ctx.Request.RemoteIP)
+		require.Equal(t, "my ua", ctx.Request.UserAgent)
+		require.Equal(t, [][]string{{"X-Forwarded-For", "5.6.7.8"}}, ctx.Request.Headers)
+		require.Equal(t, 200, ctx.Response.Status)
+		require.Empty(t, traces[0].ParentID)
+		require.Empty(t, traces[0].Data)
+	})
+
+	t.Run("ignored spans", func(t *testing.T) {
+		_, span := tracer.Start(context.Background(), "client", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			attribute.String("http.request.method", "GET"),
+		))
+		span.End()
+		_, span = tracer.Start(context.Background(), "rpc", trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+		))
+		span.End()
+		require.Empty(t, takeTraces(&exporter))
+	})
+}
+
+// takeTraces returns and removes the exported traces.
+func takeTraces(e *testutil.Exporter) []*sqhttp.Trace {
+	var traces []*sqhttp.Trace
+	for _, s := range e.Take() {
+		traces = append(traces, s.(*sqhttp.Trace))
+	}
+	return traces
+}
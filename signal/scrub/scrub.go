@@ -0,0 +1,335 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package scrub provides the scrubber of personally identifiable information
+// and secrets from signal values before they are exported. Values are
+// redacted when their key name matches the key regular expression of the
+// scrubber, while substrings of other values are redacted when they match one
+// of its value rules, such as credit card numbers or emails.
+package scrub
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Redacted is the value replacing redacted values.
+const Redacted = "[redacted]"
+
+// DefaultKeyRegexp matches key names usually associated with secrets.
+var DefaultKeyRegexp = regexp.MustCompile(`(?i)passw(or)?d|passwd|secret|token|api[-_]?key|authorization|cookie|session|credential|private[-_]?key|ssn|cvv|cvc`)
+
+// ValueRule is a rule redacting the substrings of values matching Regexp, and
+// accepted by Validate when not nil.
+type ValueRule struct {
+	// Name is the name of the rule.
+	Name     string
+	Regexp   *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// Value rules of the default scrubber.
+var (
+	CreditCardRule = ValueRule{
+		Name:     "credit_card",
+		Regexp:   regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Validate: luhn,
+	}
+	JWTRule = ValueRule{
+		Name:   "jwt",
+		Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	}
+	BearerTokenRule = ValueRule{
+		Name:   "bearer_token",
+		Regexp: regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	}
+	EmailRule = ValueRule{
+		Name:   "email",
+		Regexp: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+
+	DefaultValueRules = []ValueRule{BearerTokenRule, JWTRule, CreditCardRule, EmailRule}
+)
+
+// Scrubber redacts values by key name and by value rules.
+type Scrubber struct {
+	keys   *regexp.Regexp
+	values []ValueRule
+}
+
+// NewScrubber returns a new scrubber redacting the values of the keys matching
+// the given regular expression, and the substrings of values matching the
+// given value rules. Keys are not considered when keys is nil.
+func NewScrubber(keys *regexp.Regexp, values []ValueRule) *Scrubber {
+	return &Scrubber{
+		keys:   keys,
+		values: values,
+	}
+}
+
+// NewDefaultScrubber returns a new scrubber using DefaultKeyRegexp and
+// DefaultValueRules.
+func NewDefaultScrubber() *Scrubber {
+	return NewScrubber(DefaultKeyRegexp, DefaultValueRules)
+}
+
+// Report records the paths of the fields redacted by a scrubber. Paths are
+// dot-separated key names, with array indices between brackets, eg.
+// "request.parameters.json.users[0].password".
+type Report struct {
+	Fields []string
+}
+
+func (r *Report) add(path string) {
+	if r != nil {
+		r.Fields = append(r.Fields, path)
+	}
+}
+
+// MatchKey returns true when values of the given key name must be redacted.
+func (s *Scrubber) MatchKey(key string) bool {
+	return s.keys != nil && s.keys.MatchString(key)
+}
+
+// String returns the string with its substrings matching the value rules
+// redacted. The path is recorded into the report when the string is modified.
+func (s *Scrubber) String(path, v string, r *Report) string {
+	scrubbed := v
+	for _, rule := range s.values {
+		scrubbed = rule.Regexp.ReplaceAllStringFunc(scrubbed, func(match string) string {
+			if rule.Validate != nil && !rule.Validate(match) {
+				return match
+			}
+			return Redacted
+		})
+	}
+	if scrubbed != v {
+		r.add(path)
+	}
+	return scrubbed
+}
+
+// Pairs returns a scrubbed copy of the list of key-value pairs, such as HTTP
+// headers. Pairs whose first element is a key matching the key regular
+// expression have their values redacted, while other pairs have their values
+// scrubbed by value rules.
+func (s *Scrubber) Pairs(path string, pairs [][]string, r *Report) [][]string {
+	if pairs == nil {
+		return nil
+	}
+	scrubbed := make([][]string, len(pairs))
+	for i, pair := range pairs {
+		if len(pair) == 0 {
+			scrubbed[i] = pair
+			continue
+		}
+		key := pair[0]
+		values := make([]string, len(pair))
+		values[0] = key
+		for j, v := range pair[1:] {
+			values[j+1] = s.keyValue(join(path, key), key, v, r)
+		}
+		scrubbed[i] = values
+	}
+	return scrubbed
+}
+
+// URL returns the URL with its user information redacted, and its query
+// parameters and fragment scrubbed by key and by value, the rest of the URL
+// being scrubbed by value. Fragments not made of key-value pairs, such as HTML
+// anchors, are scrubbed by value.
+func (s *Scrubber) URL(path, rawURL string, r *Report) string {
+	if rawURL == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return s.String(path, rawURL, r)
+	}
+	if u.User != nil {
+		r.add(join(path, "userinfo"))
+		u.User = url.User(Redacted)
+	}
+	if u.RawQuery != "" {
+		query, err := url.ParseQuery(u.RawQuery)
+		if err != nil {
+			return s.String(path, rawURL, r)
+		}
+		u.RawQuery = s.query(path, query, r)
+	}
+	if u.Fragment != "" {
+		if fragment, err := url.ParseQuery(u.EscapedFragment()); err == nil && strings.Contains(u.Fragment, "=") {
+			u.RawFragment = s.query(path, fragment, r)
+			u.Fragment, _ = url.PathUnescape(u.RawFragment)
+		} else {
+			u.Fragment = s.String(path, u.Fragment, r)
+			u.RawFragment = ""
+		}
+	}
+	u.Path = s.String(path, u.Path, r)
+	u.RawPath = ""
+	return u.String()
+}
+
+func (s *Scrubber) query(path string, query url.Values, r *Report) string {
+	scrubbed := s.Value(path, map[string][]string(query), r).(map[string][]string)
+	return url.Values(scrubbed).Encode()
+}
+
+// Value returns a scrubbed copy of the value. Maps and arrays are scrubbed
+// recursively, map values being redacted when their key matches the key
+// regular expression and strings being scrubbed by value rules. Values of
+// other types than the JSON ones are converted into their JSON
+// representation before being scrubbed. The paths of the redacted fields are
+// relative to the given path.
+func (s *Scrubber) Value(path string, v interface{}, r *Report) interface{} {
+	switch v := v.(type) {
+	case nil, bool, float64, float32, int, int64, int32, uint, uint64, uint32, json.Number:
+		return v
+
+	case string:
+		return s.String(path, v, r)
+
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		scrubbed := make(map[string]interface{}, len(v))
+		for _, k := range sortedKeys(v) {
+			p := join(path, k)
+			if s.MatchKey(k) {
+				r.add(p)
+				scrubbed[k] = Redacted
+				continue
+			}
+			scrubbed[k] = s.Value(p, v[k], r)
+		}
+		return scrubbed
+
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		scrubbed := make([]interface{}, len(v))
+		for i, e := range v {
+			scrubbed[i] = s.Value(index(path, i), e, r)
+		}
+		return scrubbed
+
+	case map[string][]string:
+		if v == nil {
+			return v
+		}
+		scrubbed := make(map[string][]string, len(v))
+		for _, k := range sortedKeys(v) {
+			values := make([]string, len(v[k]))
+			for i, e := range v[k] {
+				values[i] = s.keyValue(join(path, k), k, e, r)
+			}
+			scrubbed[k] = values
+		}
+		return scrubbed
+
+	case map[string]string:
+		if v == nil {
+			return v
+		}
+		scrubbed := make(map[string]string, len(v))
+		for _, k := range sortedKeys(v) {
+			scrubbed[k] = s.keyValue(join(path, k), k, v[k], r)
+		}
+		return scrubbed
+
+	case []string:
+		if v == nil {
+			return v
+		}
+		scrubbed := make([]string, len(v))
+		for i, e := range v {
+			scrubbed[i] = s.String(index(path, i), e, r)
+		}
+		return scrubbed
+
+	case [][]string:
+		return s.Pairs(path, v, r)
+
+	default:
+		// Convert the value into its JSON representation in order to scrub it.
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return v
+		}
+		var generic interface{}
+		if err := json.Unmarshal(buf, &generic); err != nil {
+			return v
+		}
+		return s.Value(path, generic, r)
+	}
+}
+
+func (s *Scrubber) keyValue(path, key, v string, r *Report) string {
+	if s.MatchKey(key) {
+		r.add(path)
+		return Redacted
+	}
+	return s.String(path, v, r)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func index(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]interface{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string][]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]string:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// luhn returns true when the digits of the string pass the Luhn checksum.
+func luhn(s string) bool {
+	var sum, n int
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package slog forwards the security-relevant log records of log/slog as
+// points attached to the request trace (cf. package trace).
+package slog
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client"
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/scrub"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+const (
+	// PayloadSchema is the payload schema of the log points. The payload is
+	// the map of the record attributes, groups being nested maps.
+	PayloadSchema = "log/2020-01-01T00:00:00.000Z"
+	// LevelTag is the tag of the record level of the log points.
+	LevelTag = "log.level"
+)
+
+// Handler is a slog.Handler passing every record through to the wrapped
+// handler and forwarding the records matching its filters as points, named
+// after the record message. Points are added to the trace collector of the
+// record context, or exported when the context has no collector.
+type Handler struct {
+	// Source is the signal source of the points.
+	Source string
+	// Level is the minimum level of the records forwarded as points.
+	// slog.LevelInfo is used when nil.
+	Level slog.Leveler
+	// Keys are the attribute keys of the records forwarded as points: only the
+	// records having at least one of them, including the attributes added by
+	// WithAttrs(), are forwarded when not empty. Keys of grouped attributes
+	// are not qualified by their groups.
+	Keys []string
+	// Scrubber scrubs the payload of the points. A default scrubber is used
+	// when nil (cf. scrub.NewDefaultScrubber()).
+	Scrubber *scrub.Scrubber
+
+	next     slog.Handler
+	exporter client.Exporter
+	attrs    []groupedAttr
+	groups   []string
+}
+
+// groupedAttr is an attribute added by WithAttrs() in its groups.
+type groupedAttr struct {
+	groups []string
+	attr   slog.Attr
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+var defaultScrubber = scrub.NewDefaultScrubber()
+
+// NewHandler returns a new handler wrapping next and exporting the points of
+// records without trace collector using the exporter, or dropping them when
+// the exporter is nil.
+func NewHandler(next slog.Handler, exporter client.Exporter) *Handler {
+	return &Handler{next: next, exporter: exporter}
+}
+
+// Enabled returns true when the wrapped handler or the point forwarding is
+// enabled for the level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.enabled(level) || h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) enabled(level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.Level != nil {
+		min = h.Level.Level()
+	}
+	return level >= min
+}
+
+// Handle forwards the record as a point when it matches the filters, and
+// passes it to the wrapped handler when enabled.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.enabled(r.Level) && h.match(r) {
+		h.forward(ctx, r)
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *Handler) match(r slog.Record) bool {
+	if len(h.Keys) == 0 {
+		return true
+	}
+	for _, a := range h.attrs {
+		if h.hasKey(a.attr) {
+			return true
+		}
+	}
+	found := false
+	r.Attrs(func(a slog.Attr) bool {
+		found = h.hasKey(a)
+		return !found
+	})
+	return found
+}
+
+func (h *Handler) hasKey(a slog.Attr) bool {
+	if a.Value.Kind() == slog.KindGroup {
+		for _, a := range a.Value.Group() {
+			if h.hasKey(a) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, k := range h.Keys {
+		if a.Key == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) forward(ctx context.Context, r slog.Record) {
+	payload := make(map[string]interface{})
+	for _, a := range h.attrs {
+		addAttr(group(payload, a.groups), a.attr)
+	}
+	attrs := group(payload, h.groups)
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(attrs, a)
+		return true
+	})
+	scrubber := h.Scrubber
+	if scrubber == nil {
+		scrubber = defaultScrubber
+	}
+
+	p, err := api.NewPointBuilder().
+		Name(r.Message).
+		Source(h.Source).
+		Time(r.Time).
+		Location(api.LocationOf(r.PC)).
+		Tag(LevelTag, r.Level.String()).
+		Payload(PayloadSchema, scrubber.Value("", payload, nil)).
+		Build()
+	if err != nil {
+		return
+	}
+	if trace.FromContext(ctx) != nil {
+		trace.AddPoint(ctx, p)
+	} else if h.exporter != nil {
+		h.exporter.Export(p)
+	}
+}
+
+// WithAttrs returns a handler whose points and wrapped handler records have
+// the attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	c := h.clone(h.next.WithAttrs(attrs))
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, groupedAttr{groups: h.groups, attr: a})
+	}
+	return c
+}
+
+// WithGroup returns a handler whose points and wrapped handler records have
+// their attributes in the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	c := h.clone(h.next.WithGroup(name))
+	c.groups = append(c.groups[:len(c.groups):len(c.groups)], name)
+	return c
+}
+
+func (h *Handler) clone(next slog.Handler) *Handler {
+	c := *h
+	c.next = next
+	c.attrs = c.attrs[:len(c.attrs):len(c.attrs)]
+	return &c
+}
+
+// group returns the nested map of the groups, creating it when needed.
+func group(m map[string]interface{}, groups []string) map[string]interface{} {
+	for _, g := range groups {
+		sub, ok := m[g].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[g] = sub
+		}
+		m = sub
+	}
+	return m
+}
+
+// addAttr adds the attribute to the map, following the slog.Handler rules:
+// empty attributes are ignored and groups without key are inlined.
+func addAttr(m map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return
+		}
+		if a.Key != "" {
+			m = group(m, []string{a.Key})
+		}
+		for _, a := range attrs {
+			addAttr(m, a)
+		}
+	default:
+		m[a.Key] = jsonValue(a.Value)
+	}
+}
+
+// jsonValue returns the value as a JSON-safe value: values not representable
+// in JSON, such as channels, functions or infinite numbers, are converted into
+// their string representation.
+func jsonValue(v slog.Value) interface{} {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindFloat64:
+		if f := v.Float64(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return err.Error()
+		}
+		if _, err := json.Marshal(v.Any()); err == nil {
+			return v.Any()
+		}
+	}
+	return v.String()
+}
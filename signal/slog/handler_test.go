@@ -0,0 +1,128 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package slog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/internal/testutil"
+	"github.com/sqreen/go-sdk/signal/scrub"
+	sqslog "github.com/sqreen/go-sdk/signal/slog"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	newLogger := func(configure func(*sqslog.Handler)) (*slog.Logger, *bytes.Buffer, *testutil.Exporter) {
+		var buf bytes.Buffer
+		var exporter testutil.Exporter
+		h := sqslog.NewHandler(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), &exporter)
+		h.Source = "test"
+		if configure != nil {
+			configure(h)
+		}
+		return slog.New(h), &buf, &exporter
+	}
+
+	t.Run("trace point", func(t *testing.T) {
+		logger, buf, exporter := newLogger(nil)
+		collector := trace.NewCollector(0, nil)
+		ctx := trace.NewContext(context.Background(), collector)
+
+		logger = logger.With("user", "alice").WithGroup("authz")
+		logger.WarnContext(ctx, "access denied", "resource", "/admin", slog.Group("policy", "name", "admins"), "error", errors.New("forbidden"))
+
+		require.Empty(t, exporter.Signals())
+		signals := collector.Signals()
+		require.Len(t, signals, 1)
+		p := signals[0]
+		require.Equal(t, "point", p.Type)
+		require.Equal(t, "access denied", p.Name)
+		require.Equal(t, "test", p.Source)
+		require.Equal(t, map[string]string{sqslog.LevelTag: "WARN"}, p.Tags)
+		require.Equal(t, sqslog.PayloadSchema, p.SignalPayload.Schema)
+		require.Equal(t, map[string]interface{}{
+			"user": "alice",
+			"authz": map[string]interface{}{
+				"resource": "/admin",
+				"policy":   map[string]interface{}{"name": "admins"},
+				"error":    "forbidden",
+			},
+		}, p.SignalPayload.Payload)
+		require.NotNil(t, p.Location)
+		require.Len(t, p.Location.Stack, 1)
+		require.True(t, strings.HasSuffix(p.Location.Stack[0].File, "handler_test.go"))
+
+		require.Contains(t, buf.String(), `level=WARN msg="access denied" user=alice authz.resource=/admin authz.policy.name=admins authz.error=forbidden`)
+	})
+
+	t.Run("without trace", func(t *testing.T) {
+		logger, _, exporter := newLogger(nil)
+		logger.Info("suspicious input")
+		require.Len(t, exporter.Signals(), 1)
+		require.Equal(t, "suspicious input", exporter.Signals()[0].(*api.Point).Name)
+	})
+
+	t.Run("level filter", func(t *testing.T) {
+		logger, buf, exporter := newLogger(func(h *sqslog.Handler) {
+			h.Level = slog.LevelWarn
+		})
+		logger.Debug("debug")
+		logger.Info("info")
+		logger.Error("error")
+		require.Len(t, exporter.Signals(), 1)
+		require.Equal(t, "error", exporter.Signals()[0].(*api.Point).Name)
+		require.Equal(t, 3, strings.Count(buf.String(), "\n"))
+	})
+
+	t.Run("key filter", func(t *testing.T) {
+		logger, buf, exporter := newLogger(func(h *sqslog.Handler) {
+			h.Keys = []string{"security"}
+		})
+		logger.Info("no key", "other", true)
+		logger.Info("record key", "security", true)
+		logger.WithGroup("g").Info("grouped key", slog.Group("sub", "security", true))
+		logger.With("security", true).Info("handler key")
+		require.Len(t, exporter.Signals(), 3)
+		require.Equal(t, "record key", exporter.Signals()[0].(*api.Point).Name)
+		require.Equal(t, "grouped key", exporter.Signals()[1].(*api.Point).Name)
+		require.Equal(t, "handler key", exporter.Signals()[2].(*api.Point).Name)
+		require.Equal(t, 4, strings.Count(buf.String(), "\n"))
+	})
+
+	t.Run("payload values", func(t *testing.T) {
+		logger, _, exporter := newLogger(nil)
+		logger.Info("login", "password", "1234", "ch", make(chan int), "f", func() {}, "inf", math.Inf(1), "d", time.Second, "user", struct{ Name string }{"bob"})
+		require.Len(t, exporter.Signals(), 1)
+		payload := exporter.Signals()[0].(*api.Point).SignalPayload.Payload.(map[string]interface{})
+		require.Equal(t, scrub.Redacted, payload["password"])
+		require.IsType(t, "", payload["ch"])
+		require.IsType(t, "", payload["f"])
+		require.Equal(t, "+Inf", payload["inf"])
+		require.Equal(t, "1s", payload["d"])
+		require.Equal(t, map[string]interface{}{"Name": "bob"}, payload["user"])
+		_, err := json.Marshal(payload)
+		require.NoError(t, err)
+
+		t.Run("custom scrubber", func(t *testing.T) {
+			logger, _, exporter := newLogger(func(h *sqslog.Handler) {
+				h.Scrubber = scrub.NewScrubber(regexp.MustCompile(`^user$`), nil)
+			})
+			logger.Info("login", "user", "bob")
+			payload := exporter.Signals()[0].(*api.Point).SignalPayload.Payload
+			require.Equal(t, map[string]interface{}{"user": scrub.Redacted}, payload)
+		})
+	})
+}
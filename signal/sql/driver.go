@@ -0,0 +1,304 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+)
+
+// Wrap returns a driver wrapping the given one in order to record the SQL
+// queries as points of the request traces.
+func Wrap(d driver.Driver, cfg Config) driver.Driver {
+	return &wrappedDriver{Driver: d, rec: newRecorder(d, cfg)}
+}
+
+// WrapConnector returns a connector wrapping the given one in order to record
+// the SQL queries as points of the request traces. It allows to use the
+// wrapper with sql.OpenDB().
+func WrapConnector(c driver.Connector, cfg Config) driver.Connector {
+	rec := newRecorder(c.Driver(), cfg)
+	return &wrappedConnector{Connector: c, driver: &wrappedDriver{Driver: c.Driver(), rec: rec}, rec: rec}
+}
+
+type wrappedDriver struct {
+	driver.Driver
+	rec *recorder
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return newWrappedConn(c, d.rec), nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &wrappedConnector{Connector: c, driver: d, rec: d.rec}, nil
+	}
+	return &dsnConnector{name: name, driver: d}, nil
+}
+
+type wrappedConnector struct {
+	driver.Connector
+	driver *wrappedDriver
+	rec    *recorder
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newWrappedConn(conn, c.rec), nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver { return c.driver }
+
+// dsnConnector is the connector of drivers not implementing
+// driver.DriverContext.
+type dsnConnector struct {
+	name   string
+	driver *wrappedDriver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.name) }
+func (c *dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+// wrappedConn implements the optional context interfaces of connections and
+// falls back to the non-context ones of the wrapped connection. The execer
+// and queryer interfaces are only implemented by the variants returned by
+// newWrappedConn() when the wrapped connection implements them, since
+// database/sql checks the arguments against the connection before using them.
+type wrappedConn struct {
+	driver.Conn
+	rec *recorder
+}
+
+func newWrappedConn(c driver.Conn, rec *recorder) driver.Conn {
+	wc := &wrappedConn{Conn: c, rec: rec}
+	_, execerCtx := c.(driver.ExecerContext)
+	_, execer := c.(driver.Execer) //nolint:staticcheck
+	_, queryerCtx := c.(driver.QueryerContext)
+	_, queryer := c.(driver.Queryer) //nolint:staticcheck
+	switch {
+	case (execerCtx || execer) && (queryerCtx || queryer):
+		return &wrappedExecerQueryerConn{wc}
+	case execerCtx || execer:
+		return &wrappedExecerConn{wc}
+	case queryerCtx || queryer:
+		return &wrappedQueryerConn{wc}
+	default:
+		return wc
+	}
+}
+
+type wrappedExecerConn struct{ *wrappedConn }
+
+func (c *wrappedExecerConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.exec(ctx, query, args)
+}
+
+type wrappedQueryerConn struct{ *wrappedConn }
+
+func (c *wrappedQueryerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(ctx, query, args)
+}
+
+type wrappedExecerQueryerConn struct{ *wrappedConn }
+
+func (c *wrappedExecerQueryerConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.exec(ctx, query, args)
+}
+
+func (c *wrappedExecerQueryerConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.query(ctx, query, args)
+}
+
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ws := &wrappedStmt{Stmt: stmt, conn: c.Conn, query: query, rec: c.rec}
+	if _, ok := stmt.(driver.ColumnConverter); ok { //nolint:staticcheck
+		return &wrappedConverterStmt{ws}, nil
+	}
+	return ws, nil
+}
+
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support non-default transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck
+}
+
+func (c *wrappedConn) exec(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if ec, ok := c.Conn.(driver.ExecerContext); ok {
+		res, err = ec.ExecContext(ctx, query, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = c.Conn.(driver.Execer).Exec(query, values) //nolint:staticcheck
+		}
+	}
+	if err != driver.ErrSkip {
+		c.rec.record(ctx, query, start, rowsAffected(res, err), err)
+	}
+	return res, err
+}
+
+func (c *wrappedConn) query(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if qc, ok := c.Conn.(driver.QueryerContext); ok {
+		rows, err = qc.QueryContext(ctx, query, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = c.Conn.(driver.Queryer).Query(query, values) //nolint:staticcheck
+		}
+	}
+	if err != driver.ErrSkip {
+		c.rec.record(ctx, query, start, nil, err)
+	}
+	return rows, err
+}
+
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *wrappedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *wrappedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedStmt checks the arguments like database/sql does for the wrapped
+// statement: with its driver.NamedValueChecker, or else the one of its
+// connection.
+type wrappedStmt struct {
+	driver.Stmt
+	conn  driver.Conn
+	query string
+	rec   *recorder
+}
+
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = s.Stmt.Exec(values) //nolint:staticcheck
+		}
+	}
+	s.rec.record(ctx, s.query, start, rowsAffected(res, err), err)
+	return res, err
+}
+
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values) //nolint:staticcheck
+		}
+	}
+	s.rec.record(ctx, s.query, start, nil, err)
+	return rows, err
+}
+
+func (s *wrappedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if nvc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(v)
+	}
+	if nvc, ok := s.conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedConverterStmt is the wrapped statement of the statements
+// implementing driver.ColumnConverter.
+type wrappedConverterStmt struct{ *wrappedStmt }
+
+func (s *wrappedConverterStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.(driver.ColumnConverter).ColumnConverter(idx) //nolint:staticcheck
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func rowsAffected(res driver.Result, err error) *int64 {
+	if err != nil || res == nil {
+		return nil
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil
+	}
+	return &n
+}
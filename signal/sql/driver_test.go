@@ -0,0 +1,252 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	sqsql "github.com/sqreen/go-sdk/signal/sql"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDriver(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		driver driver.Driver
+	}{
+		{name: "prepared statements", driver: fakeDriver{}},
+		{name: "context interfaces", driver: fakeContextDriver{}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			db := sql.OpenDB(sqsql.WrapConnector(dsnConnector{tc.driver}, sqsql.Config{Source: "test", MaxPoints: 3}))
+			defer db.Close()
+
+			t.Run("without trace", func(t *testing.T) {
+				_, err := db.ExecContext(context.Background(), "DELETE FROM users")
+				require.NoError(t, err)
+			})
+
+			c := trace.NewCollector(0, nil)
+			ctx := trace.NewContext(context.Background(), c)
+
+			res, err := db.ExecContext(ctx, "UPDATE users SET name = 'john' WHERE id = ?", 42)
+			require.NoError(t, err)
+			n, err := res.RowsAffected()
+			require.NoError(t, err)
+			require.Equal(t, int64(1), n)
+
+			rows, err := db.QueryContext(ctx, "SELECT name FROM users WHERE id IN (1, 2)")
+			require.NoError(t, err)
+			require.NoError(t, rows.Close())
+
+			_, err = db.ExecContext(ctx, "DROP TABLE users")
+			require.Error(t, err)
+
+			// Capped by MaxPoints
+			_, err = db.ExecContext(ctx, "DELETE FROM users")
+			require.NoError(t, err)
+
+			signals := c.Signals()
+			require.Len(t, signals, 3)
+			require.Equal(t, 1, c.Dropped())
+
+			for _, s := range signals {
+				require.Equal(t, sqsql.PointName, s.Name)
+				require.Equal(t, "test", s.Source)
+				require.Equal(t, sqsql.PayloadSchema, s.SignalPayload.Schema)
+			}
+
+			p := signals[0].SignalPayload.Payload.(*sqsql.Payload)
+			require.Equal(t, "UPDATE users SET name = ? WHERE id = ?", p.Statement)
+			require.Equal(t, "users", p.Table)
+			require.NotNil(t, p.RowsAffected)
+			require.Equal(t, int64(1), *p.RowsAffected)
+			require.Empty(t, p.Error)
+
+			p = signals[1].SignalPayload.Payload.(*sqsql.Payload)
+			require.Equal(t, "SELECT name FROM users WHERE id IN (?)", p.Statement)
+			require.Nil(t, p.RowsAffected)
+
+			p = signals[2].SignalPayload.Payload.(*sqsql.Payload)
+			require.Equal(t, "DROP TABLE users", p.Statement)
+			// The error message is not recorded
+			require.Equal(t, "*fmt.wrapError", p.Error)
+			require.Equal(t, "42501", p.SQLState)
+			require.Nil(t, p.RowsAffected)
+		})
+	}
+}
+
+func TestDriverDialect(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		dialect  *sqsql.Dialect
+		expected string
+	}{
+		{name: "unknown", expected: "SELECT * FROM ? WHERE name = ?"},
+		{name: "mysql", dialect: &sqsql.MySQLDialect, expected: "SELECT * FROM `users` WHERE name = ?"},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			db := sql.OpenDB(sqsql.WrapConnector(dsnConnector{fakeContextDriver{}}, sqsql.Config{Dialect: tc.dialect}))
+			defer db.Close()
+			c := trace.NewCollector(0, nil)
+			defer c.Release()
+			_, err := db.ExecContext(trace.NewContext(context.Background(), c), "SELECT * FROM `users` WHERE name = \"john\"")
+			require.NoError(t, err)
+			signals := c.Signals()
+			require.Len(t, signals, 1)
+			require.Equal(t, tc.expected, signals[0].SignalPayload.Payload.(*sqsql.Payload).Statement)
+		})
+	}
+
+	require.Equal(t, sqsql.Dialect{}, sqsql.DialectOf(fakeDriver{}))
+}
+
+func TestDriverArgumentChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		driver driver.Driver
+	}{
+		{name: "connection checker", driver: fakeCheckerDriver{}},
+		{name: "connection checker and execer", driver: fakeExecerDriver{}},
+		{name: "statement converter", driver: fakeConverterDriver{}},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			db := sql.OpenDB(sqsql.WrapConnector(dsnConnector{tc.driver}, sqsql.Config{}))
+			defer db.Close()
+			c := trace.NewCollector(0, nil)
+			defer c.Release()
+			ctx := trace.NewContext(context.Background(), c)
+
+			_, err := db.ExecContext(ctx, "DELETE FROM users WHERE name IN (?)", []string{"john", "bob"})
+			require.NoError(t, err)
+
+			stmt, err := db.PrepareContext(ctx, "DELETE FROM users WHERE name IN (?)")
+			require.NoError(t, err)
+			defer stmt.Close()
+			_, err = stmt.ExecContext(ctx, []string{"john", "bob"})
+			require.NoError(t, err)
+
+			require.Len(t, c.Signals(), 2)
+		})
+	}
+}
+
+type dsnConnector struct{ d driver.Driver }
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open("") }
+func (c dsnConnector) Driver() driver.Driver                        { return c.d }
+
+// fakeDriver only implements the mandatory driver interfaces.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.query == "DROP TABLE users" {
+		return nil, fmt.Errorf("exec: %w", &sqlStateError{state: "42501"})
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeStmt) Query([]driver.Value) (driver.Rows, error) { return fakeRows{}, nil }
+
+type sqlStateError struct{ state string }
+
+func (e *sqlStateError) Error() string    { return "permission denied for user 'secret'" }
+func (e *sqlStateError) SQLState() string { return e.state }
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string         { return []string{"name"} }
+func (fakeRows) Close() error              { return nil }
+func (fakeRows) Next([]driver.Value) error { return io.EOF }
+
+// fakeContextDriver returns connections implementing the context interfaces.
+type fakeContextDriver struct{}
+
+func (fakeContextDriver) Open(string) (driver.Conn, error) { return fakeContextConn{}, nil }
+
+type fakeContextConn struct{ fakeConn }
+
+func (fakeContextConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return fakeStmt{query: query}.Exec(nil)
+}
+
+func (fakeContextConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return fakeRows{}, nil
+}
+
+// fakeCheckerDriver returns connections accepting string slice arguments.
+type fakeCheckerDriver struct{}
+
+func (fakeCheckerDriver) Open(string) (driver.Conn, error) { return fakeCheckerConn{}, nil }
+
+type fakeCheckerConn struct{ fakeConn }
+
+func (fakeCheckerConn) CheckNamedValue(v *driver.NamedValue) error {
+	if _, ok := v.Value.([]string); ok {
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// fakeExecerDriver returns connections accepting string slice arguments and
+// implementing the non-context execer interface.
+type fakeExecerDriver struct{}
+
+func (fakeExecerDriver) Open(string) (driver.Conn, error) { return fakeExecerConn{}, nil }
+
+type fakeExecerConn struct{ fakeCheckerConn }
+
+func (fakeExecerConn) Exec(query string, _ []driver.Value) (driver.Result, error) {
+	return fakeStmt{query: query}.Exec(nil)
+}
+
+// fakeConverterDriver returns statements converting string slice arguments.
+type fakeConverterDriver struct{}
+
+func (fakeConverterDriver) Open(string) (driver.Conn, error) { return fakeConverterConn{}, nil }
+
+type fakeConverterConn struct{ fakeConn }
+
+func (fakeConverterConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeConverterStmt{fakeStmt{query: query}}, nil
+}
+
+type fakeConverterStmt struct{ fakeStmt }
+
+func (fakeConverterStmt) ColumnConverter(int) driver.ValueConverter { return stringsConverter{} }
+
+type stringsConverter struct{}
+
+func (stringsConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if s, ok := v.([]string); ok {
+		return strings.Join(s, ","), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
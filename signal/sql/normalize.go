@@ -0,0 +1,247 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package sql
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Dialect describes the lexical rules of a SQL dialect the normalization of
+// its statements depends on. The zero Dialect is the unknown dialect (cf.
+// Normalize()).
+type Dialect struct {
+	// IdentifierQuotes are the characters quoting identifiers, such as `"`
+	// in standard SQL. Strings quoted with them are kept as they are, other
+	// quoted strings being replaced.
+	IdentifierQuotes string
+	// BackslashEscapes is true when backslashes escape characters in string
+	// literals, such as in MySQL. Backslashes are otherwise only escapes in
+	// PostgreSQL escape strings, eg. E'it\'s'.
+	BackslashEscapes bool
+}
+
+// Dialects of the common drivers. SQLite and SQL Server accept double-quoted
+// string literals, so that double-quoted strings are replaced in them, SQL
+// Server being the unknown dialect.
+var (
+	StandardDialect   = Dialect{IdentifierQuotes: `"`}
+	PostgreSQLDialect = Dialect{IdentifierQuotes: `"`}
+	MySQLDialect      = Dialect{IdentifierQuotes: "`", BackslashEscapes: true}
+	SQLiteDialect     = Dialect{IdentifierQuotes: "`"}
+	SQLServerDialect  = Dialect{}
+)
+
+// RedactedStatement is the normalization of the statements that cannot be
+// safely normalized.
+const RedactedStatement = "?"
+
+// Normalize returns the statement of the unknown dialect with its literals
+// replaced by "?", every quoted string included (cf. Dialect.Normalize()).
+// Since backslashes may or may not escape characters in string literals,
+// statements normalized differently according to it are entirely redacted
+// (cf. RedactedStatement).
+func Normalize(statement string) string {
+	return Dialect{}.Normalize(statement)
+}
+
+// Normalize returns the statement with its literals replaced by "?": string
+// literals, dollar-quoted strings, numbers and booleans. Comments are removed,
+// whitespaces are collapsed, and lists of placeholders such as "(?, ?, ?)"
+// are collapsed into "(?)". Quoted identifiers are kept as they are.
+func (d Dialect) Normalize(statement string) string {
+	if d == (Dialect{}) {
+		normalized := normalize(statement, "", false)
+		if strings.IndexByte(statement, '\\') >= 0 && normalize(statement, "", true) != normalized {
+			return RedactedStatement
+		}
+		return normalized
+	}
+	return normalize(statement, d.IdentifierQuotes, d.BackslashEscapes)
+}
+
+func normalize(statement, identifierQuotes string, backslashEscapes bool) string {
+	var b strings.Builder
+	b.Grow(len(statement))
+	space := false
+	writeSpace := func() {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+	}
+
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+
+		case c == '-' && strings.HasPrefix(statement[i:], "--"):
+			end := strings.IndexByte(statement[i:], '\n')
+			if end < 0 {
+				end = len(statement) - i
+			}
+			space = true
+			i += end
+
+		case c == '/' && strings.HasPrefix(statement[i:], "/*"):
+			end := strings.Index(statement[i+2:], "*/")
+			if end < 0 {
+				i = len(statement)
+			} else {
+				i += end + 4
+			}
+			space = true
+
+		case c == '"' || c == '`' || c == '\'':
+			writeSpace()
+			end := skipQuoted(statement, i, c, backslashEscapes || (c == '\'' && escapeStringPrefix(statement, i)))
+			if c != '\'' && strings.IndexByte(identifierQuotes, c) >= 0 {
+				b.WriteString(statement[i:end])
+			} else {
+				b.WriteByte('?')
+			}
+			i = end
+
+		case c == '$' && i+1 < len(statement) && (statement[i+1] == '$' || isIdentStart(statement[i+1])):
+			// Dollar-quoted string $tag$...$tag$, or $n placeholder
+			if tag, ok := dollarTag(statement[i:]); ok {
+				writeSpace()
+				b.WriteByte('?')
+				end := strings.Index(statement[i+len(tag):], tag)
+				if end < 0 {
+					i = len(statement)
+				} else {
+					i += len(tag) + end + len(tag)
+				}
+				continue
+			}
+			writeSpace()
+			b.WriteByte(c)
+			i++
+
+		case isDigit(c) || (c == '.' && i+1 < len(statement) && isDigit(statement[i+1])):
+			writeSpace()
+			if i > 0 && (isIdentPart(statement[i-1]) || statement[i-1] == '$') {
+				// Part of an identifier or of a placeholder
+				b.WriteByte(c)
+				i++
+				continue
+			}
+			b.WriteByte('?')
+			i = skipNumber(statement, i)
+
+		case isIdentStart(c):
+			writeSpace()
+			end := i
+			for end < len(statement) && isIdentPart(statement[end]) {
+				end++
+			}
+			word := statement[i:end]
+			if strings.EqualFold(word, "true") || strings.EqualFold(word, "false") {
+				word = "?"
+			}
+			b.WriteString(word)
+			i = end
+
+		default:
+			writeSpace()
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return placeholderListRegexp.ReplaceAllString(b.String(), "(?)")
+}
+
+var placeholderListRegexp = regexp.MustCompile(`\(\s*\?(\s*,\s*\?)+\s*\)`)
+
+// skipQuoted returns the index following the quoted string starting at i,
+// quotes being escaped by doubling them, or with a backslash when
+// backslashEscapes is true.
+func skipQuoted(s string, i int, quote byte, backslashEscapes bool) int {
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if backslashEscapes {
+				j++
+			}
+		case quote:
+			if j+1 < len(s) && s[j+1] == quote {
+				j++
+				continue
+			}
+			return j + 1
+		}
+	}
+	return len(s)
+}
+
+// escapeStringPrefix returns true when the string literal starting at i is a
+// PostgreSQL escape string, ie. prefixed with E.
+func escapeStringPrefix(s string, i int) bool {
+	return i > 0 && (s[i-1] == 'E' || s[i-1] == 'e') && (i == 1 || !isIdentPart(s[i-2]))
+}
+
+// dollarTag returns the opening tag of the dollar-quoted string s starts with.
+func dollarTag(s string) (string, bool) {
+	for j := 1; j < len(s); j++ {
+		if s[j] == '$' {
+			return s[:j+1], true
+		}
+		if !isIdentPart(s[j]) {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func skipNumber(s string, i int) int {
+	if strings.HasPrefix(s[i:], "0x") || strings.HasPrefix(s[i:], "0X") {
+		i += 2
+		for i < len(s) && isHexDigit(s[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+		i++
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		for i < len(s) && isDigit(s[i]) {
+			i++
+		}
+	}
+	return i
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || c >= 0x80
+}
+
+func isIdentPart(c byte) bool { return isIdentStart(c) || isDigit(c) }
+
+var tableRegexp = regexp.MustCompile("(?i)\\b(?:from|into|update|join|table)\\s+((?:[\\w$]+|\"[^\"]+\"|`[^`]+`)(?:\\.(?:[\\w$]+|\"[^\"]+\"|`[^`]+`))?)")
+
+// Table returns the first table name found in the statement, or an empty
+// string when none.
+func Table(statement string) string {
+	m := tableRegexp.FindStringSubmatch(statement)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package sql_test
+
+import (
+	"testing"
+
+	sqsql "github.com/sqreen/go-sdk/signal/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		statement, expected string
+		dialect             sqsql.Dialect
+	}{
+		{
+			statement: "SELECT * FROM users WHERE id = 42",
+			expected:  "SELECT * FROM users WHERE id = ?",
+		},
+		{
+			statement: "SELECT * FROM users WHERE email = 'john@doe.com' AND password = 'it''s \\' secret'",
+			expected:  "SELECT * FROM users WHERE email = ? AND password = ?",
+			dialect:   sqsql.MySQLDialect,
+		},
+		{
+			statement: "select  *\n\tfrom t1 -- comment 'x'\n where /* 'y' */ a = -1.5e10 and b = 0xFF",
+			expected:  "select * from t1 where a = -? and b = ?",
+		},
+		{
+			statement: "SELECT * FROM users WHERE id IN (1, 2, 3) AND active = true",
+			expected:  "SELECT * FROM users WHERE id IN (?) AND active = ?",
+		},
+		{
+			statement: "INSERT INTO `my table` (\"col1\", col2) VALUES ($1, $2)",
+			expected:  "INSERT INTO `my table` (?, col2) VALUES ($1, $2)",
+			dialect:   sqsql.SQLiteDialect,
+		},
+		{
+			statement: "SELECT $$it's a secret$$, $tag$another$tag$ FROM t2",
+			expected:  "SELECT ?, ? FROM t2",
+		},
+		{
+			statement: "SELECT col1, t3.col2 FROM t3 WHERE x = ?",
+			expected:  "SELECT col1, t3.col2 FROM t3 WHERE x = ?",
+		},
+		// Quoted strings of unknown dialects may be string literals
+		{
+			statement: "INSERT INTO `my table` (\"col1\", col2) VALUES ($1, $2)",
+			expected:  "INSERT INTO ? (?, col2) VALUES ($1, $2)",
+		},
+		// Double-quoted strings are string literals in MySQL
+		{
+			statement: "SELECT * FROM `users` WHERE name = \"john\" AND password = \"it\\\"s\"",
+			expected:  "SELECT * FROM `users` WHERE name = ? AND password = ?",
+			dialect:   sqsql.MySQLDialect,
+		},
+		{
+			statement: "SELECT * FROM \"users\" WHERE name = 'john'",
+			expected:  "SELECT * FROM \"users\" WHERE name = ?",
+			dialect:   sqsql.PostgreSQLDialect,
+		},
+		// Double-quoted strings may be string literals in SQLite and SQL Server
+		{
+			statement: "SELECT * FROM users WHERE email = \"bob@example.com\"",
+			expected:  "SELECT * FROM users WHERE email = ?",
+			dialect:   sqsql.SQLiteDialect,
+		},
+		{
+			statement: "SELECT * FROM [users] WHERE email = \"bob@example.com\"",
+			expected:  "SELECT * FROM [users] WHERE email = ?",
+			dialect:   sqsql.SQLServerDialect,
+		},
+		// Backslashes only escape characters in the dialects using them
+		{
+			statement: "SELECT * FROM t WHERE a = 'a\\' AND b = 'secret'",
+			expected:  "SELECT * FROM t WHERE a = ? AND b = ?",
+			dialect:   sqsql.StandardDialect,
+		},
+		{
+			statement: "SELECT * FROM t WHERE a = 'a\\' AND b = 'secret'",
+			expected:  "SELECT * FROM t WHERE a = ?secret?",
+			dialect:   sqsql.MySQLDialect,
+		},
+		{
+			statement: "SELECT E'it\\'s', 'a\\' FROM t",
+			expected:  "SELECT E?, ? FROM t",
+			dialect:   sqsql.PostgreSQLDialect,
+		},
+		// Statements of unknown dialects depending on backslash escapes are
+		// redacted
+		{
+			statement: "SELECT * FROM t WHERE a = 'a\\' AND b = 'secret'",
+			expected:  sqsql.RedactedStatement,
+		},
+		{
+			statement: "SELECT * FROM t WHERE a = 'a\\b'",
+			expected:  "SELECT * FROM t WHERE a = ?",
+		},
+	} {
+		tc := tc
+		t.Run(tc.statement, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.dialect.Normalize(tc.statement))
+			if tc.dialect == (sqsql.Dialect{}) {
+				require.Equal(t, tc.expected, sqsql.Normalize(tc.statement))
+			}
+		})
+	}
+}
+
+func TestTable(t *testing.T) {
+	for _, tc := range []struct {
+		statement, expected string
+	}{
+		{statement: "SELECT * FROM users WHERE id = ?", expected: "users"},
+		{statement: "insert into public.users values (?)", expected: "public.users"},
+		{statement: "UPDATE `my table` SET a = ?", expected: "`my table`"},
+		{statement: "DELETE FROM \"Users\"", expected: "\"Users\""},
+		{statement: "SELECT 1", expected: ""},
+	} {
+		tc := tc
+		t.Run(tc.statement, func(t *testing.T) {
+			require.Equal(t, tc.expected, sqsql.Table(tc.statement))
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package sql provides a database/sql driver wrapper recording the SQL queries
+// executed during a request as point signals attached to the request trace
+// (cf. package trace). Statements are normalized by stripping their literals
+// so that no data leaves the process.
+//
+// The wrapped driver is usually registered under a new name:
+//
+//	sql.Register("sqreen-postgres", sqsql.Wrap(&pq.Driver{}, sqsql.Config{}))
+//	db, err := sql.Open("sqreen-postgres", dsn)
+//
+// Queries are only recorded when they are given a request context, eg. using
+// db.QueryContext(r.Context(), ...).
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/trace"
+)
+
+const (
+	// PointName is the name of SQL query points.
+	PointName = "sql_query"
+	// PayloadSchema is the payload schema of SQL query points.
+	PayloadSchema = "sql_query/2020-01-01T00:00:00.000Z"
+	// DefaultMaxPoints is the maximum number of SQL query points per trace
+	// when the configuration doesn't specify any.
+	DefaultMaxPoints = 32
+)
+
+// Config is the configuration of the driver wrapper. Default values are used
+// for zero fields.
+type Config struct {
+	// Source is the signal source of the points.
+	Source string
+	// MaxPoints is the maximum number of SQL query points per trace.
+	MaxPoints int
+	// Dialect is the SQL dialect of the wrapped driver. The dialect of the
+	// common drivers is detected from their package when nil (cf.
+	// DialectOf()).
+	Dialect *Dialect
+}
+
+// Payload is the payload of SQL query points.
+type Payload struct {
+	// Statement is the normalized statement (cf. Normalize()).
+	Statement string `json:"statement"`
+	// Table is the first table name found in the statement.
+	Table string `json:"table,omitempty"`
+	// Duration is the query duration in milliseconds.
+	Duration float64 `json:"duration_ms"`
+	// RowsAffected is the number of rows affected by the statement
+	// execution, when known.
+	RowsAffected *int64 `json:"rows_affected,omitempty"`
+	// Error is the type of the query error, if any, such as *pq.Error. The
+	// error message is not recorded as it may contain data.
+	Error string `json:"error,omitempty"`
+	// SQLState is the SQLSTATE code of the query error, when the error
+	// provides it with a SQLState() string method.
+	SQLState string `json:"sql_state,omitempty"`
+}
+
+type recorder struct {
+	source    string
+	maxPoints int
+	dialect   Dialect
+}
+
+func newRecorder(d driver.Driver, cfg Config) *recorder {
+	if cfg.MaxPoints <= 0 {
+		cfg.MaxPoints = DefaultMaxPoints
+	}
+	dialect := DialectOf(d)
+	if cfg.Dialect != nil {
+		dialect = *cfg.Dialect
+	}
+	return &recorder{source: cfg.Source, maxPoints: cfg.MaxPoints, dialect: dialect}
+}
+
+// DialectOf returns the dialect of the common drivers according to their
+// package path, or the unknown dialect.
+func DialectOf(d driver.Driver) Dialect {
+	t := reflect.TypeOf(d)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil {
+		return Dialect{}
+	}
+	pkg := strings.ToLower(t.PkgPath())
+	switch {
+	case strings.Contains(pkg, "mysql"):
+		return MySQLDialect
+	case strings.Contains(pkg, "lib/pq"), strings.Contains(pkg, "pgx"):
+		return PostgreSQLDialect
+	case strings.Contains(pkg, "sqlite"):
+		return SQLiteDialect
+	case strings.Contains(pkg, "mssql"), strings.Contains(pkg, "sqlserver"):
+		return SQLServerDialect
+	default:
+		return Dialect{}
+	}
+}
+
+// record adds the SQL query point to the trace collector of the context, if
+// any.
+func (r *recorder) record(ctx context.Context, query string, start time.Time, rowsAffected *int64, err error) {
+	c := trace.FromContext(ctx)
+	if c == nil {
+		return
+	}
+	statement := r.dialect.Normalize(query)
+	payload := &Payload{
+		Statement:    statement,
+		Table:        Table(statement),
+		Duration:     float64(time.Since(start)) / float64(time.Millisecond),
+		RowsAffected: rowsAffected,
+	}
+	if err != nil {
+		payload.Error, payload.SQLState = describeError(err)
+	}
+	p := api.NewPoint(PointName, r.source, start, nil, nil, nil, nil, nil, api.NewPayload(PayloadSchema, payload))
+	c.AddWithLimit((*api.Signal)(p), r.maxPoints)
+}
+
+// describeError returns the type and SQLSTATE code of the query error, or the
+// message of the context and driver errors known not to contain data.
+func describeError(err error) (typ, sqlState string) {
+	var withState interface{ SQLState() string }
+	if errors.As(err, &withState) {
+		sqlState = withState.SQLState()
+	}
+	for _, known := range []error{context.Canceled, context.DeadlineExceeded, driver.ErrBadConn} {
+		if errors.Is(err, known) {
+			return known.Error(), sqlState
+		}
+	}
+	return fmt.Sprintf("%T", err), sqlState
+}
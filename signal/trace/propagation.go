@@ -0,0 +1,125 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context header propagating the trace
+// identifiers across services.
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a trace and the position of the current service in
+// it, using W3C Trace Context identifiers: 32 and 16 lowercase hexadecimal
+// characters for trace and span IDs.
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	// ParentID is the span ID of the caller, empty for root spans.
+	ParentID string
+	Sampled  bool
+}
+
+// NewSpanContext returns the span context of a new sampled trace.
+func NewSpanContext() SpanContext {
+	return SpanContext{
+		TraceID: newID(16),
+		SpanID:  newID(8),
+		Sampled: true,
+	}
+}
+
+// Child returns the span context of a child span in the same trace.
+func (sc SpanContext) Child() SpanContext {
+	return SpanContext{
+		TraceID:  sc.TraceID,
+		SpanID:   newID(8),
+		ParentID: sc.SpanID,
+		Sampled:  sc.Sampled,
+	}
+}
+
+// Traceparent returns the traceparent header value propagating the span
+// context, the span being the parent of the callee.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent returns the span context of the caller propagated by the
+// traceparent header value. It returns false when the value is not valid.
+func ParseTraceparent(v string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version 00 has exactly four parts, and version ff is forbidden. Future
+	// versions may append parts.
+	if !isHexID(version, 1) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if !isHexID(traceID, 16) || !isHexID(spanID, 8) || !isHexID(flags, 1) {
+		return SpanContext{}, false
+	}
+	flagBits, _ := hex.DecodeString(flags)
+	return SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: flagBits[0]&1 == 1,
+	}, true
+}
+
+// isHexID returns true when s is the lowercase hexadecimal representation of
+// n bytes, not all zero.
+func isHexID(s string, n int) bool {
+	if len(s) != 2*n {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+		zero = zero && c == '0'
+	}
+	// Only the one-byte version and flags fields can be zero
+	return !zero || n == 1
+}
+
+func newID(n int) string {
+	b := make([]byte, n)
+	for {
+		_, _ = rand.Read(b)
+		for _, c := range b {
+			if c != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+type spanContextKey struct{}
+
+// WithSpanContext returns a copy of the context storing the span context.
+func WithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context stored in the context. It
+// returns false when none.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package trace_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanContext(t *testing.T) {
+	sc := trace.NewSpanContext()
+	require.Len(t, sc.TraceID, 32)
+	require.Len(t, sc.SpanID, 16)
+	require.Empty(t, sc.ParentID)
+	require.True(t, sc.Sampled)
+	require.NotEqual(t, sc.TraceID, trace.NewSpanContext().TraceID)
+
+	child := sc.Child()
+	require.Equal(t, sc.TraceID, child.TraceID)
+	require.Equal(t, sc.SpanID, child.ParentID)
+	require.NotEqual(t, sc.SpanID, child.SpanID)
+
+	parsed, ok := trace.ParseTraceparent(child.Traceparent())
+	require.True(t, ok)
+	require.Equal(t, trace.SpanContext{TraceID: child.TraceID, SpanID: child.SpanID, Sampled: true}, parsed)
+
+	ctx := context.Background()
+	_, ok = trace.SpanContextFromContext(ctx)
+	require.False(t, ok)
+	got, ok := trace.SpanContextFromContext(trace.WithSpanContext(ctx, sc))
+	require.True(t, ok)
+	require.Equal(t, sc, got)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected *trace.SpanContext
+	}{
+		{
+			value:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expected: &trace.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+		},
+		{
+			value:    "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			expected: &trace.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+		},
+		{
+			// Future version with extra fields
+			value:    "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-extra",
+			expected: &trace.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true},
+		},
+		{value: ""},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"},
+		{value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{value: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+		{value: "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01"},
+	} {
+		tc := tc
+		t.Run(tc.value, func(t *testing.T) {
+			sc, ok := trace.ParseTraceparent(tc.value)
+			if tc.expected == nil {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, *tc.expected, sc)
+		})
+	}
+}
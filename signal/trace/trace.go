@@ -0,0 +1,249 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+// Package trace provides the request-scoped collector of the signals attached
+// to the trace of a request. Middlewares, such as the HTTP one, store the
+// collector in the request context so that any function having it can attach
+// signals to the request trace.
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+)
+
+const (
+	// DefaultMaxSignals is the maximum number of signals of a collector when
+	// NewCollector is given zero.
+	DefaultMaxSignals = 100
+	// DefaultBudgetMaxSignals and DefaultBudgetMaxBytes are the limits of the
+	// budget shared by the collectors created without budget.
+	DefaultBudgetMaxSignals = 10000
+	DefaultBudgetMaxBytes   = 32 << 20
+)
+
+// defaultBudget is the budget of the collectors created without budget.
+var defaultBudget = NewBudget(DefaultBudgetMaxSignals, DefaultBudgetMaxBytes)
+
+// Collector collects the signals of a trace. It is safe for concurrent use.
+type Collector struct {
+	max     int
+	budget  *Budget
+	mu      sync.Mutex
+	signals []*api.Signal
+	// bytes is the number of bytes acquired from the budget, by the signals
+	// and by Reserve().
+	bytes       int64
+	dropped     int
+	released    bool
+	identifiers map[string]string
+}
+
+// NewCollector returns a new collector of at most max signals. Every signal is
+// also acquired from the budget, or from a default budget shared by the
+// collectors created without budget when nil, so that the collector must be
+// released once its signals are no longer needed (cf. Release()).
+func NewCollector(max int, budget *Budget) *Collector {
+	if max <= 0 {
+		max = DefaultMaxSignals
+	}
+	if budget == nil {
+		budget = defaultBudget
+	}
+	return &Collector{max: max, budget: budget}
+}
+
+// Add adds the signal to the collector. It returns false when the signal was
+// dropped because the collector or its budget is full.
+func (c *Collector) Add(s *api.Signal) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.add(s)
+}
+
+// AddWithLimit adds the signal to the collector unless it already has max
+// signals of the same name. It returns false when the signal was dropped.
+func (c *Collector) AddWithLimit(s *api.Signal, max int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, e := range c.signals {
+		if e.Name == s.Name {
+			n++
+		}
+	}
+	if n >= max {
+		c.dropped++
+		return false
+	}
+	return c.add(s)
+}
+
+func (c *Collector) add(s *api.Signal) bool {
+	if len(c.signals) >= c.max || c.released {
+		c.dropped++
+		return false
+	}
+	size := signalSize(s)
+	if !c.budget.acquire(1, size) {
+		c.dropped++
+		return false
+	}
+	c.signals = append(c.signals, s)
+	c.bytes += size
+	return true
+}
+
+// Reserve acquires the given number of bytes from the budget for other data
+// buffered along with the signals, such as the request parameters. It returns
+// false when the budget is full or the collector released.
+func (c *Collector) Reserve(bytes int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.released || !c.budget.acquire(0, bytes) {
+		return false
+	}
+	c.bytes += bytes
+	return true
+}
+
+// Release releases the budget acquired by the collector. Signals added
+// afterwards are dropped.
+func (c *Collector) Release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.released {
+		return
+	}
+	c.released = true
+	c.budget.release(len(c.signals), c.bytes)
+}
+
+// signalSize returns the estimated memory size of the signal, ie. the size of
+// its JSON representation.
+func signalSize(s *api.Signal) int64 {
+	buf, err := json.Marshal(s)
+	if err != nil {
+		return 0
+	}
+	return int64(len(buf))
+}
+
+// SetIdentifiers sets the identifiers of the actor of the trace, such as its
+// user ID.
+func (c *Collector) SetIdentifiers(identifiers map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.identifiers = identifiers
+}
+
+// Identifiers returns the actor identifiers of the trace.
+func (c *Collector) Identifiers() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.identifiers
+}
+
+// Signals returns the signals collected so far.
+func (c *Collector) Signals() []*api.Signal {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	signals := make([]*api.Signal, len(c.signals))
+	copy(signals, c.signals)
+	return signals
+}
+
+// Dropped returns the number of signals dropped so far.
+func (c *Collector) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
+
+// Budget limits the total number of signals and bytes buffered by the
+// collectors sharing it, so that buffering the signals of every in-flight
+// request cannot exhaust the memory. It is safe for concurrent use.
+type Budget struct {
+	maxSignals, maxBytes int64
+	mu                   sync.Mutex
+	signals, bytes       int64
+}
+
+// NewBudget returns a new budget of at most maxSignals signals and maxBytes
+// bytes. Zero limits are disabled.
+func NewBudget(maxSignals int, maxBytes int64) *Budget {
+	return &Budget{maxSignals: int64(maxSignals), maxBytes: maxBytes}
+}
+
+// Used returns the number of signals currently acquired from the budget.
+func (b *Budget) Used() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return int(b.signals)
+}
+
+// UsedBytes returns the number of bytes currently acquired from the budget.
+func (b *Budget) UsedBytes() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bytes
+}
+
+func (b *Budget) acquire(signals int, bytes int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.maxSignals > 0 && b.signals+int64(signals) > b.maxSignals {
+		return false
+	}
+	if b.maxBytes > 0 && b.bytes+bytes > b.maxBytes {
+		return false
+	}
+	b.signals += int64(signals)
+	b.bytes += bytes
+	return true
+}
+
+func (b *Budget) release(signals int, bytes int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.signals -= int64(signals)
+	b.bytes -= bytes
+}
+
+type collectorKey struct{}
+
+// NewContext returns a copy of the context storing the collector.
+func NewContext(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// FromContext returns the collector stored in the context, or nil when none.
+func FromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(collectorKey{}).(*Collector)
+	return c
+}
+
+// AddPoint adds the point to the collector of the context. It returns false
+// when the context has no collector or when the collector is full.
+func AddPoint(ctx context.Context, p *api.Point) bool {
+	c := FromContext(ctx)
+	if c == nil {
+		return false
+	}
+	return c.Add((*api.Signal)(p))
+}
+
+// Identify sets the actor identifiers of the trace of the context. It returns
+// false when the context has no collector.
+func Identify(ctx context.Context, identifiers map[string]string) bool {
+	c := FromContext(ctx)
+	if c == nil {
+		return false
+	}
+	c.SetIdentifiers(identifiers)
+	return true
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2016 - 2020 Sqreen. All Rights Reserved.
+// Please refer to our terms for more information:
+// https://www.sqreen.io/terms.html
+
+package trace_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sqreen/go-sdk/signal/client/api"
+	"github.com/sqreen/go-sdk/signal/trace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget(t *testing.T) {
+	newPoint := func(payload string) *api.Signal {
+		return (*api.Signal)(api.NewPoint("my point", "test", time.Now(), nil, nil, nil, nil, nil, api.NewPayload("my schema", payload)))
+	}
+
+	t.Run("signals", func(t *testing.T) {
+		budget := trace.NewBudget(2, 0)
+		c1 := trace.NewCollector(0, budget)
+		c2 := trace.NewCollector(0, budget)
+		require.True(t, c1.Add(newPoint("a")))
+		require.True(t, c2.Add(newPoint("b")))
+		require.False(t, c1.Add(newPoint("c")))
+		require.Equal(t, 2, budget.Used())
+		require.Equal(t, 1, c1.Dropped())
+
+		c1.Release()
+		require.Equal(t, 1, budget.Used())
+		require.True(t, c2.Add(newPoint("d")))
+		c2.Release()
+		require.Equal(t, 0, budget.Used())
+		require.Zero(t, budget.UsedBytes())
+	})
+
+	t.Run("bytes", func(t *testing.T) {
+		budget := trace.NewBudget(0, 1024)
+		c := trace.NewCollector(0, budget)
+		require.True(t, c.Add(newPoint("small")))
+		require.NotZero(t, budget.UsedBytes())
+		require.False(t, c.Add(newPoint(strings.Repeat("a", 1024))))
+		require.True(t, c.Reserve(100))
+		require.False(t, c.Reserve(1024))
+
+		c.Release()
+		require.Zero(t, budget.UsedBytes())
+		require.False(t, c.Reserve(1))
+	})
+
+	t.Run("default budget", func(t *testing.T) {
+		c := trace.NewCollector(0, nil)
+		defer c.Release()
+		require.False(t, c.Reserve(trace.DefaultBudgetMaxBytes+1))
+		require.True(t, c.Add(newPoint("a")))
+	})
+}